@@ -0,0 +1,163 @@
+package mctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/yankeguo/minit/internal/mrunners"
+)
+
+const usage = `usage: minit ctl <command> [arguments]
+
+commands:
+  status                  show status of all units
+  start <unit>            start a stopped unit
+  stop <unit>             stop a unit
+  restart <unit>          restart a unit
+  signal <unit> <signal>  send a signal to processes of a unit, e.g. SIGHUP`
+
+// Client talks to the control API of a running minit
+type Client struct {
+	http *http.Client
+}
+
+// NewClient creates a new Client connecting to the given socket
+func NewClient(socket string) *Client {
+	return &Client{
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+func (c *Client) do(method string, path string, out any) (err error) {
+	var req *http.Request
+	if req, err = http.NewRequest(method, "http://minit"+path, nil); err != nil {
+		return
+	}
+
+	var res *http.Response
+	if res, err = c.http.Do(req); err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var e errorResponse
+		if err = json.NewDecoder(res.Body).Decode(&e); err != nil || e.Error == "" {
+			err = errors.New("unexpected response: " + res.Status)
+			return
+		}
+		err = errors.New(e.Error)
+		return
+	}
+
+	if out != nil {
+		err = json.NewDecoder(res.Body).Decode(out)
+	}
+	return
+}
+
+// Units returns runtime information of all units
+func (c *Client) Units() (units []mrunners.UnitInfo, err error) {
+	err = c.do(http.MethodGet, "/units", &units)
+	return
+}
+
+// Start starts a unit
+func (c *Client) Start(name string) error {
+	return c.do(http.MethodPost, "/units/"+url.PathEscape(name)+"/start", nil)
+}
+
+// Stop stops a unit
+func (c *Client) Stop(name string) error {
+	return c.do(http.MethodPost, "/units/"+url.PathEscape(name)+"/stop", nil)
+}
+
+// Restart restarts a unit
+func (c *Client) Restart(name string) error {
+	return c.do(http.MethodPost, "/units/"+url.PathEscape(name)+"/restart", nil)
+}
+
+// Signal sends a signal to processes of a unit
+func (c *Client) Signal(name string, sig string) error {
+	return c.do(http.MethodPost, "/units/"+url.PathEscape(name)+"/signal?signal="+url.QueryEscape(sig), nil)
+}
+
+// Run executes a 'minit ctl' command line, args excludes 'ctl' itself
+func (c *Client) Run(args []string, out io.Writer) (err error) {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	expect := func(n int) error {
+		if len(args) != n+1 {
+			return errors.New(usage)
+		}
+		return nil
+	}
+
+	switch args[0] {
+	case "status":
+		if err = expect(0); err != nil {
+			return
+		}
+		var units []mrunners.UnitInfo
+		if units, err = c.Units(); err != nil {
+			return
+		}
+		printUnits(out, units)
+	case "start":
+		if err = expect(1); err != nil {
+			return
+		}
+		err = c.Start(args[1])
+	case "stop":
+		if err = expect(1); err != nil {
+			return
+		}
+		err = c.Stop(args[1])
+	case "restart":
+		if err = expect(1); err != nil {
+			return
+		}
+		err = c.Restart(args[1])
+	case "signal":
+		if err = expect(2); err != nil {
+			return
+		}
+		err = c.Signal(args[1], args[2])
+	default:
+		err = fmt.Errorf("unknown command '%s'\n%s", args[0], usage)
+	}
+	return
+}
+
+func printUnits(out io.Writer, units []mrunners.UnitInfo) {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tKIND\tSTATE\tREADY\tPIDS")
+	for _, unit := range units {
+		var pids []string
+		for _, pid := range unit.PIDs {
+			pids = append(pids, strconv.Itoa(pid))
+		}
+		if len(pids) == 0 {
+			pids = []string{"-"}
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%s\n", unit.Name, unit.Kind, unit.State, unit.Ready, strings.Join(pids, ","))
+	}
+	_ = tw.Flush()
+}
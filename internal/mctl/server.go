@@ -0,0 +1,177 @@
+package mctl
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/yankeguo/minit/internal/mexec"
+	"github.com/yankeguo/minit/internal/mlog"
+	"github.com/yankeguo/minit/internal/mrunners"
+)
+
+const (
+	DefaultSocket = "/run/minit.sock"
+
+	// SocketDisabled disables the control socket if used as socket path
+	SocketDisabled = "none"
+)
+
+type ServerOptions struct {
+	Socket     string
+	Supervisor *mrunners.Supervisor
+	Logger     mlog.ProcLogger
+}
+
+// Server serves the control API over a unix socket, access is protected by permissions of the socket file
+type Server struct {
+	opts   ServerOptions
+	lis    net.Listener
+	server *http.Server
+}
+
+// NewServer creates a new Server
+func NewServer(opts ServerOptions) *Server {
+	s := &Server{opts: opts}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /units", s.handleUnits)
+	mux.HandleFunc("POST /units/{name}/start", s.handleStart)
+	mux.HandleFunc("POST /units/{name}/stop", s.handleStop)
+	mux.HandleFunc("POST /units/{name}/restart", s.handleRestart)
+	mux.HandleFunc("POST /units/{name}/signal", s.handleSignal)
+
+	s.server = &http.Server{Handler: mux}
+	return s
+}
+
+// Start listens on the socket and serves in background
+func (s *Server) Start() (err error) {
+	if err = removeStaleSocket(s.opts.Socket); err != nil {
+		return
+	}
+
+	if s.lis, err = listenPrivate(s.opts.Socket); err != nil {
+		return
+	}
+
+	s.opts.Logger.Printf("control socket listening on %s", s.opts.Socket)
+
+	go func() {
+		if err := s.server.Serve(s.lis); err != nil && err != http.ErrServerClosed {
+			s.opts.Logger.Errorf("control socket server failed: %s", err.Error())
+		}
+	}()
+	return
+}
+
+// removeStaleSocket removes the socket left by a previous run, a socket still served by another process is kept
+func removeStaleSocket(socket string) error {
+	info, err := os.Lstat(socket)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err == nil {
+		_ = conn.Close()
+		return errors.New("socket " + socket + " is in use by another process")
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return err
+	}
+	return os.Remove(socket)
+}
+
+// listenPrivate listens on socket with mode 0600. The socket is bound in a directory only accessible by minit and
+// linked to its path after chmod, so other users have no window to connect, and the umask of minit is left untouched.
+func listenPrivate(socket string) (lis net.Listener, err error) {
+	var dir string
+	if dir, err = os.MkdirTemp(filepath.Dir(socket), ".minit-sock-"); err != nil {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "sock")
+
+	var ul *net.UnixListener
+	if ul, err = net.ListenUnix("unix", &net.UnixAddr{Name: file, Net: "unix"}); err != nil {
+		return
+	}
+	// file is removed with dir, the linked socket is removed by Close
+	ul.SetUnlinkOnClose(false)
+
+	if err = os.Chmod(file, 0600); err == nil {
+		err = os.Link(file, socket)
+	}
+	if err != nil {
+		_ = ul.Close()
+		return
+	}
+
+	lis = ul
+	return
+}
+
+// Close stops serving and removes the socket file
+func (s *Server) Close() {
+	if s.lis == nil {
+		return
+	}
+	_ = s.server.Close()
+	_ = os.Remove(s.opts.Socket)
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func respond(rw http.ResponseWriter, err error, data any) {
+	rw.Header().Set("Content-Type", "application/json")
+
+	if err != nil {
+		if errors.Is(err, mrunners.ErrUnitNotFound) {
+			rw.WriteHeader(http.StatusNotFound)
+		} else {
+			rw.WriteHeader(http.StatusBadRequest)
+		}
+		data = errorResponse{Error: err.Error()}
+	} else if data == nil {
+		data = struct{}{}
+	}
+
+	_ = json.NewEncoder(rw).Encode(data)
+}
+
+func (s *Server) handleUnits(rw http.ResponseWriter, req *http.Request) {
+	units := s.opts.Supervisor.Units()
+	if units == nil {
+		units = []mrunners.UnitInfo{}
+	}
+	respond(rw, nil, units)
+}
+
+func (s *Server) handleStart(rw http.ResponseWriter, req *http.Request) {
+	respond(rw, s.opts.Supervisor.StartUnit(req.PathValue("name")), nil)
+}
+
+func (s *Server) handleStop(rw http.ResponseWriter, req *http.Request) {
+	respond(rw, s.opts.Supervisor.StopUnit(req.Context(), req.PathValue("name")), nil)
+}
+
+func (s *Server) handleRestart(rw http.ResponseWriter, req *http.Request) {
+	respond(rw, s.opts.Supervisor.RestartUnit(req.Context(), req.PathValue("name")), nil)
+}
+
+func (s *Server) handleSignal(rw http.ResponseWriter, req *http.Request) {
+	sig, err := mexec.ParseSignal(req.URL.Query().Get("signal"))
+	if err != nil {
+		respond(rw, err, nil)
+		return
+	}
+	respond(rw, s.opts.Supervisor.SignalUnit(req.PathValue("name"), sig), nil)
+}
@@ -0,0 +1,147 @@
+package mctl
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yankeguo/minit/internal/mexec"
+	"github.com/yankeguo/minit/internal/mlog"
+	"github.com/yankeguo/minit/internal/mrunners"
+	"github.com/yankeguo/minit/internal/munit"
+	"github.com/yankeguo/rg"
+)
+
+// syncBuffer is a bytes.Buffer safe for loggers writing from many goroutines while the test reads it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestServer(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	buf := &syncBuffer{}
+
+	logger := rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{
+		ConsoleOut: buf,
+		ConsoleErr: buf,
+	}))
+
+	sup := mrunners.NewSupervisor(exem, logger)
+
+	sup.Start(rg.Must(mrunners.Create(mrunners.RunnerOptions{
+		Unit: munit.Unit{
+			Kind:    munit.KindDaemon,
+			Name:    "sleeper",
+			Shell:   "/bin/bash",
+			Command: []string{"trap 'echo got hup' HUP", "while true; do sleep 0.1; done"},
+		},
+		Exec:     exem,
+		Logger:   logger,
+		Registry: mrunners.NewRegistry(),
+	})))
+	defer sup.Shutdown(context.Background(), syscall.SIGTERM)
+
+	socket := filepath.Join(t.TempDir(), "minit.sock")
+
+	s := NewServer(ServerOptions{
+		Socket:     socket,
+		Supervisor: sup,
+		Logger:     logger,
+	})
+	require.NoError(t, s.Start())
+	defer s.Close()
+
+	info, err := os.Lstat(socket)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	time.Sleep(time.Millisecond * 500)
+
+	c := NewClient(socket)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, c.Run([]string{"status"}, out))
+	require.Contains(t, out.String(), "sleeper")
+	require.Contains(t, out.String(), mrunners.StateRunning)
+
+	require.NoError(t, c.Run([]string{"signal", "sleeper", "SIGHUP"}, out))
+	time.Sleep(time.Millisecond * 300)
+	require.Contains(t, buf.String(), "got hup")
+
+	require.ErrorContains(t, c.Run([]string{"signal", "sleeper", "SIGWHAT"}, out), "unknown signal")
+	require.ErrorContains(t, c.Run([]string{"start", "sleeper"}, out), "already running")
+	require.ErrorContains(t, c.Run([]string{"stop", "unknown"}, out), "unit not found")
+	require.ErrorContains(t, c.Run([]string{"stop"}, out), "usage")
+
+	require.NoError(t, c.Run([]string{"stop", "sleeper"}, out))
+
+	units, err := c.Units()
+	require.NoError(t, err)
+	require.Len(t, units, 1)
+	require.Equal(t, mrunners.StateStopped, units[0].State)
+	require.False(t, units[0].Running)
+	require.Empty(t, units[0].PIDs)
+
+	require.NoError(t, c.Run([]string{"restart", "sleeper"}, out))
+
+	time.Sleep(time.Millisecond * 500)
+
+	units, err = c.Units()
+	require.NoError(t, err)
+	require.Equal(t, mrunners.StateRunning, units[0].State)
+	require.Len(t, units[0].PIDs, 1)
+}
+
+func TestServerSocket(t *testing.T) {
+	logger := rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{}))
+
+	socket := filepath.Join(t.TempDir(), "minit.sock")
+
+	// stale socket left by a previous run
+	lis, err := net.ListenUnix("unix", &net.UnixAddr{Name: socket, Net: "unix"})
+	require.NoError(t, err)
+	lis.SetUnlinkOnClose(false)
+	require.NoError(t, lis.Close())
+
+	sup := mrunners.NewSupervisor(mexec.NewManager(mexec.ManagerOptions{}), logger)
+
+	s1 := NewServer(ServerOptions{Socket: socket, Supervisor: sup, Logger: logger})
+	require.NoError(t, s1.Start())
+
+	entries, err := os.ReadDir(filepath.Dir(socket))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	// socket served by another process
+	s2 := NewServer(ServerOptions{Socket: socket, Supervisor: sup, Logger: logger})
+	require.ErrorContains(t, s2.Start(), "in use by another process")
+
+	_, err = NewClient(socket).Units()
+	require.NoError(t, err)
+
+	s1.Close()
+
+	_, err = os.Lstat(socket)
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package mexec
+
+import "errors"
+
+// ValidateCapabilities is only supported on linux
+func ValidateCapabilities(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	return errors.New("capabilities are not supported on this platform")
+}
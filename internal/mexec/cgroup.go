@@ -0,0 +1,95 @@
+package mexec
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultCgroupRoot = "/sys/fs/cgroup"
+
+	// CgroupDisabled disables cgroup controls if used as cgroup root
+	CgroupDisabled = "none"
+)
+
+var (
+	regexpCgroupMemoryMax = regexp.MustCompile(`^(max|[0-9]+[KMGTkmgt]?)$`)
+	regexpCgroupCPUMax    = regexp.MustCompile(`^(max|[0-9]+)( [0-9]+)?$`)
+	regexpCgroupPIDsMax   = regexp.MustCompile(`^(max|[0-9]+)$`)
+)
+
+// CgroupOptions are cgroup v2 resource controls of a process, empty values are not set
+type CgroupOptions struct {
+	MemoryMax string // memory.max, bytes with optional K, M, G, T suffix, or 'max'
+	CPUMax    string // cpu.max, '$MAX $PERIOD' in microseconds, or 'max'
+	PIDsMax   string // pids.max, number or 'max'
+	IOWeight  int    // io.weight, 1 to 10000
+}
+
+// IsZero returns true if no resource control is set
+func (o CgroupOptions) IsZero() bool {
+	return o == CgroupOptions{}
+}
+
+// Validate checks formats of values
+func (o CgroupOptions) Validate() error {
+	if o.MemoryMax != "" && !regexpCgroupMemoryMax.MatchString(o.MemoryMax) {
+		return errors.New("invalid memory max '" + o.MemoryMax + "', must be bytes with optional K, M, G, T suffix, or 'max'")
+	}
+	if o.CPUMax != "" && !regexpCgroupCPUMax.MatchString(o.CPUMax) {
+		return errors.New("invalid cpu max '" + o.CPUMax + "', must be '$MAX $PERIOD' in microseconds, or 'max'")
+	}
+	if o.PIDsMax != "" && !regexpCgroupPIDsMax.MatchString(o.PIDsMax) {
+		return errors.New("invalid pids max '" + o.PIDsMax + "', must be a number or 'max'")
+	}
+	if o.IOWeight < 0 || o.IOWeight > 10000 {
+		return errors.New("invalid io weight " + strconv.Itoa(o.IOWeight) + ", must be within 1 to 10000")
+	}
+	return nil
+}
+
+// cgroupFile is a controller interface file to write
+type cgroupFile struct {
+	controller string
+	name       string
+	value      string
+}
+
+func (o CgroupOptions) files() (files []cgroupFile) {
+	if o.MemoryMax != "" {
+		files = append(files, cgroupFile{controller: "memory", name: "memory.max", value: o.MemoryMax})
+	}
+	if o.CPUMax != "" {
+		files = append(files, cgroupFile{controller: "cpu", name: "cpu.max", value: o.CPUMax})
+	}
+	if o.PIDsMax != "" {
+		files = append(files, cgroupFile{controller: "pids", name: "pids.max", value: o.PIDsMax})
+	}
+	if o.IOWeight != 0 {
+		files = append(files, cgroupFile{controller: "io", name: "io.weight", value: "default " + strconv.Itoa(o.IOWeight)})
+	}
+	return
+}
+
+// oomCounter is the oom_kill counter in memory.events of a cgroup, ok is false if not available
+type oomCounter struct {
+	dir   string
+	count int64
+	ok    bool
+}
+
+// parseOOMKills returns the oom_kill counter in content of memory.events
+func parseOOMKills(buf []byte) (count int64, ok bool) {
+	for _, line := range strings.Split(string(buf), "\n") {
+		if value, found := strings.CutPrefix(line, "oom_kill "); found {
+			var err error
+			if count, err = strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+				ok = true
+			}
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,191 @@
+//go:build linux
+
+package mexec
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// cgroupInitLeaf is the child cgroup minit itself is moved into, processes are not allowed in a cgroup enabling
+	// controllers for its children
+	cgroupInitLeaf = "minit"
+)
+
+// cgroupManager creates child cgroups for processes with CgroupOptions
+type cgroupManager struct {
+	root string
+
+	mu          sync.Mutex
+	base        string          // cgroup of minit, resolved from root
+	prepared    bool            // processes of base are moved into the init leaf
+	controllers map[string]bool // controllers enabled in cgroup.subtree_control of base
+}
+
+func newCgroupManager(root string) *cgroupManager {
+	return &cgroupManager{root: root, controllers: map[string]bool{}}
+}
+
+// resolveBase returns the cgroup of minit under root, cgroup namespace makes it the root itself
+func (c *cgroupManager) resolveBase() string {
+	buf, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return c.root
+	}
+	for _, line := range strings.Split(string(buf), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			// the process was already moved into the init leaf
+			path = strings.TrimSuffix(filepath.Clean(path), "/"+cgroupInitLeaf)
+			base := filepath.Join(c.root, path)
+			if _, err := os.Stat(filepath.Join(base, "cgroup.controllers")); err == nil {
+				return base
+			}
+		}
+	}
+	return c.root
+}
+
+// baseDir returns the cgroup of minit, resolved once
+func (c *cgroupManager) baseDir() string {
+	if c.base == "" {
+		c.base = c.resolveBase()
+	}
+	return c.base
+}
+
+// prepare moves processes of base cgroup into the init leaf, and enables controllers for children
+func (c *cgroupManager) prepare(files []cgroupFile) (err error) {
+	if c.root == CgroupDisabled {
+		return errors.New("cgroup is disabled")
+	}
+
+	if !c.prepared {
+		base := c.baseDir()
+
+		if _, err = os.Stat(filepath.Join(base, "cgroup.controllers")); err != nil {
+			return errors.New("cgroup v2 is not available at " + c.root)
+		}
+
+		if err = os.MkdirAll(filepath.Join(base, cgroupInitLeaf), 0755); err != nil {
+			return
+		}
+
+		var buf []byte
+		if buf, err = os.ReadFile(filepath.Join(base, "cgroup.procs")); err != nil {
+			return
+		}
+		for _, pid := range strings.Fields(string(buf)) {
+			// processes may exit in the meantime
+			_ = writeCgroupFile(filepath.Join(base, cgroupInitLeaf, "cgroup.procs"), pid)
+		}
+
+		c.prepared = true
+	}
+
+	for _, file := range files {
+		if c.controllers[file.controller] {
+			continue
+		}
+		if err = writeCgroupFile(filepath.Join(c.base, "cgroup.subtree_control"), "+"+file.controller); err != nil {
+			return errors.New("failed enabling cgroup controller " + file.controller + ": " + err.Error())
+		}
+		c.controllers[file.controller] = true
+	}
+
+	return
+}
+
+// apply creates the child cgroup for the named process, writes resource controls, and moves the process into it,
+// oomBefore is the oom_kill counter of the child cgroup before the process was moved in, it may be reused
+func (c *cgroupManager) apply(name string, pid int, opts CgroupOptions) (dir string, oomBefore oomCounter, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files := opts.files()
+
+	if err = c.prepare(files); err != nil {
+		return
+	}
+
+	dir = c.dir(name)
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+
+	for _, file := range files {
+		if err = writeCgroupFile(filepath.Join(dir, file.name), file.value); err != nil {
+			err = errors.New("failed writing " + file.name + ": " + err.Error())
+			return
+		}
+	}
+
+	oomBefore = readOOMCounter(dir)
+
+	err = writeCgroupFile(filepath.Join(dir, "cgroup.procs"), strconv.Itoa(pid))
+	return
+}
+
+// oomKills returns the oom_kill counter of the child cgroup dir, or of the cgroup processes without their own cgroup
+// are in if dir is empty, that is the init leaf once prepared, otherwise the cgroup of minit
+func (c *cgroupManager) oomKills(dir string) (counter oomCounter) {
+	if c.root == CgroupDisabled {
+		return
+	}
+
+	if dir == "" {
+		c.mu.Lock()
+		dir = c.baseDir()
+		if c.prepared {
+			dir = filepath.Join(dir, cgroupInitLeaf)
+		}
+		c.mu.Unlock()
+	}
+
+	return readOOMCounter(dir)
+}
+
+// dir returns the child cgroup directory of the named process, 'daemon/nginx' is 'daemon-nginx'
+func (c *cgroupManager) dir(name string) string {
+	return filepath.Join(c.base, strings.ReplaceAll(name, "/", "-"))
+}
+
+// lookup returns the existing child cgroup directory of the named process, empty if cgroup was never prepared
+func (c *cgroupManager) lookup(name string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.prepared {
+		return ""
+	}
+	dir := c.dir(name)
+	if _, err := os.Stat(dir); err != nil {
+		return ""
+	}
+	return dir
+}
+
+// release removes the child cgroup, fails silently if processes remain
+func (c *cgroupManager) release(dir string) {
+	_ = os.Remove(dir)
+}
+
+// readOOMCounter reads the oom_kill counter in memory.events of dir
+func readOOMCounter(dir string) (counter oomCounter) {
+	counter.dir = dir
+	buf, err := os.ReadFile(filepath.Join(dir, "memory.events"))
+	if err != nil {
+		return
+	}
+	counter.count, counter.ok = parseOOMKills(buf)
+	return
+}
+
+func writeCgroupFile(file string, value string) error {
+	return os.WriteFile(file, []byte(value), 0644)
+}
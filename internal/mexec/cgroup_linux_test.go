@@ -0,0 +1,180 @@
+//go:build linux
+
+package mexec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yankeguo/minit/internal/mlog"
+)
+
+func TestManagerExecuteCgroup(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("cpu io memory pids"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "cgroup.procs"), []byte("1\n"), 0644))
+
+	m := NewManager(ManagerOptions{CgroupRoot: root})
+
+	dir := t.TempDir()
+
+	logger, err := mlog.NewProcLogger(mlog.ProcLoggerOptions{
+		FileOptions: &mlog.RotatingFileOptions{
+			Dir:      dir,
+			Filename: "cgroup",
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:    "daemon/sidecar",
+		Shell:   "/bin/sh",
+		Command: []string{"echo pid=$$"},
+		Cgroup: CgroupOptions{
+			MemoryMax: "512M",
+			CPUMax:    "50000 100000",
+			PIDsMax:   "100",
+			IOWeight:  50,
+		},
+		Logger: logger,
+	})
+	require.NoError(t, err)
+
+	read := func(elem ...string) string {
+		buf, err := os.ReadFile(filepath.Join(append([]string{root}, elem...)...))
+		require.NoError(t, err)
+		return strings.TrimSpace(string(buf))
+	}
+
+	// processes of root cgroup are moved into the init leaf
+	require.Equal(t, "1", read("minit", "cgroup.procs"))
+
+	require.Equal(t, "512M", read("daemon-sidecar", "memory.max"))
+	require.Equal(t, "50000 100000", read("daemon-sidecar", "cpu.max"))
+	require.Equal(t, "100", read("daemon-sidecar", "pids.max"))
+	require.Equal(t, "default 50", read("daemon-sidecar", "io.weight"))
+
+	out, err := os.ReadFile(filepath.Join(dir, "cgroup.out.log"))
+	require.NoError(t, err)
+	require.Contains(t, string(out), "pid="+read("daemon-sidecar", "cgroup.procs"))
+
+	// process still runs without cgroup available
+	m = NewManager(ManagerOptions{CgroupRoot: CgroupDisabled})
+
+	res, err := m.Execute(context.Background(), ExecuteOptions{
+		Name:    "daemon/sidecar",
+		Command: []string{"true"},
+		Cgroup:  CgroupOptions{PIDsMax: "100"},
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+	require.True(t, res.Started)
+
+	out, err = os.ReadFile(filepath.Join(dir, "cgroup.err.log"))
+	require.NoError(t, err)
+	require.Contains(t, string(out), "failed applying cgroup: cgroup is disabled")
+}
+
+func TestManagerExecuteOOM(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("memory"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "cgroup.procs"), []byte(""), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "memory.events"), []byte("low 0\nhigh 0\nmax 0\noom 0\noom_kill 2\n"), 0644))
+
+	m := NewManager(ManagerOptions{CgroupRoot: root})
+
+	logger, err := mlog.NewProcLogger(mlog.ProcLoggerOptions{})
+	require.NoError(t, err)
+
+	// killed without increase of oom_kill counter of minit
+	res, err := m.Execute(context.Background(), ExecuteOptions{
+		Name:    "daemon/killed",
+		Shell:   "/bin/sh",
+		Command: []string{"kill -KILL $$"},
+		Logger:  logger,
+	})
+	require.Error(t, err)
+	require.Equal(t, syscall.SIGKILL, res.Signal)
+	require.False(t, res.OOMKilled)
+
+	// oom_kill counter of minit increased
+	res, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:    "daemon/shared",
+		Shell:   "/bin/sh",
+		Command: []string{"printf 'oom 3\\noom_kill 3\\n' > " + filepath.Join(root, "memory.events"), "kill -KILL $$"},
+		Logger:  logger,
+	})
+	require.Error(t, err)
+	require.True(t, res.OOMKilled)
+	require.Contains(t, res.String(), "signal SIGKILL (oom killed)")
+
+	// exited normally, even if counter increased
+	res, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:    "daemon/normal",
+		Shell:   "/bin/sh",
+		Command: []string{"printf 'oom_kill 4\\n' > " + filepath.Join(root, "memory.events")},
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+	require.False(t, res.OOMKilled)
+
+	// oom_kill counter of own cgroup, created in advance to be written by the process, like the kernel does
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "daemon-own"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "daemon-own", "memory.events"), []byte("oom_kill 0\n"), 0644))
+
+	res, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:    "daemon/own",
+		Shell:   "/bin/sh",
+		Command: []string{"printf 'oom_kill 1\\n' > " + filepath.Join(root, "daemon-own", "memory.events"), "kill -KILL $$"},
+		Cgroup:  CgroupOptions{MemoryMax: "1M"},
+		Logger:  logger,
+	})
+	require.Error(t, err)
+	require.True(t, res.OOMKilled)
+
+	// own cgroup is reused with the counter of previous OOM kill
+	res, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:    "daemon/own",
+		Shell:   "/bin/sh",
+		Command: []string{"kill -KILL $$"},
+		Cgroup:  CgroupOptions{MemoryMax: "1M"},
+		Logger:  logger,
+	})
+	require.Error(t, err)
+	require.False(t, res.OOMKilled)
+
+	// minit was moved into the init leaf, OOM kills in sibling cgroups are counted in the hierarchical counter of root
+	require.NoError(t, os.WriteFile(filepath.Join(root, "minit", "memory.events"), []byte("oom_kill 0\n"), 0644))
+
+	res, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:    "daemon/sibling",
+		Shell:   "/bin/sh",
+		Command: []string{"printf 'oom_kill 9\\n' > " + filepath.Join(root, "memory.events"), "kill -KILL $$"},
+		Logger:  logger,
+	})
+	require.Error(t, err)
+	require.False(t, res.OOMKilled)
+
+	res, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:    "daemon/leaf",
+		Shell:   "/bin/sh",
+		Command: []string{"printf 'oom_kill 1\\n' > " + filepath.Join(root, "minit", "memory.events"), "kill -KILL $$"},
+		Logger:  logger,
+	})
+	require.Error(t, err)
+	require.True(t, res.OOMKilled)
+}
+
+func TestParseOOMKills(t *testing.T) {
+	count, ok := parseOOMKills([]byte("low 0\nhigh 0\nmax 12\noom 3\noom_kill 2\noom_group_kill 0\n"))
+	require.True(t, ok)
+	require.Equal(t, int64(2), count)
+
+	_, ok = parseOOMKills([]byte("low 0\n"))
+	require.False(t, ok)
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package mexec
+
+import "errors"
+
+// cgroupManager is not supported on this platform
+type cgroupManager struct{}
+
+func newCgroupManager(root string) *cgroupManager {
+	return &cgroupManager{}
+}
+
+func (c *cgroupManager) apply(name string, pid int, opts CgroupOptions) (dir string, oomBefore oomCounter, err error) {
+	err = errors.New("cgroup is not supported on this platform")
+	return
+}
+
+func (c *cgroupManager) lookup(name string) string {
+	return ""
+}
+
+func (c *cgroupManager) release(dir string) {
+}
+
+func (c *cgroupManager) oomKills(dir string) (counter oomCounter) {
+	return
+}
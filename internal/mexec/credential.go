@@ -0,0 +1,210 @@
+package mexec
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Credential is the resolved identity a process runs as
+type Credential struct {
+	UID      uint32
+	GID      uint32
+	Groups   []uint32 // supplementary groups
+	Username string   // empty if the user is not in passwd database
+	Home     string
+}
+
+// LookupCredential resolves a 'user[:group]' spec like 'docker run --user', user and group can be names or numeric
+// IDs. A numeric user not in passwd database runs with group 0 and home '/'. If not specified, supplementary groups
+// default to groups of the user in group database, or only the group of the credential if a group is given in the
+// spec or the user is not in passwd database, like 'su-exec' and 'gosu' do.
+func LookupCredential(spec string, supplementaryGroups []string) (cred Credential, err error) {
+	return lookupCredential(userDatabase{}, spec, supplementaryGroups)
+}
+
+// LookupCredentialInRoot is like LookupCredential, but resolves names with /etc/passwd and /etc/group under root, e.g.
+// for a process in chroot, an empty root is the same as LookupCredential
+func LookupCredentialInRoot(root string, spec string, supplementaryGroups []string) (cred Credential, err error) {
+	return lookupCredential(userDatabase{root: root}, spec, supplementaryGroups)
+}
+
+func lookupCredential(db userDatabase, spec string, supplementaryGroups []string) (cred Credential, err error) {
+	name, group, hasGroup := strings.Cut(spec, ":")
+	if name == "" {
+		err = errors.New("missing user in '" + spec + "'")
+		return
+	}
+	if hasGroup && group == "" {
+		err = errors.New("missing group in '" + spec + "'")
+		return
+	}
+
+	var u *user.User
+
+	if id, ok := parseID(name); ok {
+		if u, err = db.lookupUserID(name); err != nil {
+			if _, unknown := err.(user.UnknownUserIdError); !unknown {
+				return
+			}
+			err = nil
+			cred.UID = id
+			cred.Home = "/"
+		}
+	} else if u, err = db.lookupUser(name); err != nil {
+		return
+	}
+
+	if u != nil {
+		cred.UID, _ = parseID(u.Uid)
+		cred.GID, _ = parseID(u.Gid)
+		cred.Username = u.Username
+		cred.Home = u.HomeDir
+	}
+
+	if hasGroup {
+		if cred.GID, err = lookupGroupID(db, group); err != nil {
+			return
+		}
+	}
+
+	if supplementaryGroups == nil {
+		if u == nil || hasGroup {
+			cred.Groups = []uint32{cred.GID}
+			return
+		}
+		// failure of listing groups is not fatal, e.g. missing group database
+		supplementaryGroups, _ = db.groupIDs(u)
+	}
+
+	for _, item := range supplementaryGroups {
+		var gid uint32
+		if gid, err = lookupGroupID(db, item); err != nil {
+			return
+		}
+		cred.Groups = append(cred.Groups, gid)
+	}
+
+	return
+}
+
+func lookupGroupID(db userDatabase, name string) (gid uint32, err error) {
+	if id, ok := parseID(name); ok {
+		gid = id
+		return
+	}
+
+	var g *user.Group
+	if g, err = db.lookupGroup(name); err != nil {
+		return
+	}
+
+	var ok bool
+	if gid, ok = parseID(g.Gid); !ok {
+		err = errors.New("invalid gid of group '" + name + "': " + g.Gid)
+	}
+	return
+}
+
+// userDatabase looks up users and groups with os/user, or with passwd and group files under root if not empty
+type userDatabase struct {
+	root string
+}
+
+func (db userDatabase) lookupUser(name string) (*user.User, error) {
+	if db.root == "" {
+		return user.Lookup(name)
+	}
+	return db.findUser(func(u *user.User) bool { return u.Username == name }, user.UnknownUserError(name))
+}
+
+func (db userDatabase) lookupUserID(uid string) (*user.User, error) {
+	if db.root == "" {
+		return user.LookupId(uid)
+	}
+	id, _ := strconv.Atoi(uid)
+	return db.findUser(func(u *user.User) bool { return u.Uid == uid }, user.UnknownUserIdError(id))
+}
+
+func (db userDatabase) lookupGroup(name string) (*user.Group, error) {
+	if db.root == "" {
+		return user.LookupGroup(name)
+	}
+	entries, err := db.readFile("group")
+	if err != nil {
+		return nil, err
+	}
+	for _, fields := range entries {
+		if len(fields) >= 3 && fields[0] == name {
+			return &user.Group{Gid: fields[2], Name: fields[0]}, nil
+		}
+	}
+	return nil, user.UnknownGroupError(name)
+}
+
+// groupIDs returns the primary group and groups listing the user as a member
+func (db userDatabase) groupIDs(u *user.User) ([]string, error) {
+	if db.root == "" {
+		return u.GroupIds()
+	}
+	entries, err := db.readFile("group")
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{u.Gid}
+	for _, fields := range entries {
+		if len(fields) < 4 || fields[2] == u.Gid {
+			continue
+		}
+		if slices.Contains(strings.Split(fields[3], ","), u.Username) {
+			ids = append(ids, fields[2])
+		}
+	}
+	return ids, nil
+}
+
+// findUser returns the first user in passwd file matching fn, or notFound
+func (db userDatabase) findUser(fn func(u *user.User) bool, notFound error) (*user.User, error) {
+	entries, err := db.readFile("passwd")
+	if err != nil {
+		return nil, err
+	}
+	for _, fields := range entries {
+		if len(fields) < 6 {
+			continue
+		}
+		u := &user.User{Username: fields[0], Uid: fields[2], Gid: fields[3], Name: fields[4], HomeDir: fields[5]}
+		if fn(u) {
+			return u, nil
+		}
+	}
+	return nil, notFound
+}
+
+// readFile reads colon separated entries of /etc/passwd or /etc/group under root, comments and blank lines are skipped
+func (db userDatabase) readFile(name string) (entries [][]string, err error) {
+	var buf []byte
+	if buf, err = os.ReadFile(filepath.Join(db.root, "etc", name)); err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(buf), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, strings.Split(line, ":"))
+	}
+	return
+}
+
+func parseID(s string) (id uint32, ok bool) {
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return
+	}
+	return uint32(v), true
+}
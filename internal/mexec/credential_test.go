@@ -0,0 +1,140 @@
+package mexec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yankeguo/minit/internal/mlog"
+)
+
+func TestLookupCredential(t *testing.T) {
+	cred, err := LookupCredential("root", nil)
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), cred.UID)
+	require.Equal(t, uint32(0), cred.GID)
+	require.Equal(t, "root", cred.Username)
+	require.NotEmpty(t, cred.Home)
+
+	cred, err = LookupCredential("0:12345", []string{"0", "23456"})
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), cred.UID)
+	require.Equal(t, uint32(12345), cred.GID)
+	require.Equal(t, []uint32{0, 23456}, cred.Groups)
+
+	// group in spec replaces groups of the user
+	cred, err = LookupCredential("root:12345", nil)
+	require.NoError(t, err)
+	require.Equal(t, uint32(12345), cred.GID)
+	require.Equal(t, []uint32{12345}, cred.Groups)
+
+	cred, err = LookupCredential("0:12345", nil)
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), cred.UID)
+	require.Equal(t, []uint32{12345}, cred.Groups)
+
+	// numeric user not in passwd database
+	cred, err = LookupCredential("12345", nil)
+	require.NoError(t, err)
+	require.Equal(t, Credential{UID: 12345, Groups: []uint32{0}, Home: "/"}, cred)
+
+	cred, err = LookupCredential("12345:23456", nil)
+	require.NoError(t, err)
+	require.Equal(t, Credential{UID: 12345, GID: 23456, Groups: []uint32{23456}, Home: "/"}, cred)
+
+	_, err = LookupCredential("minit-no-such-user", nil)
+	require.Error(t, err)
+
+	_, err = LookupCredential("root:minit-no-such-group", nil)
+	require.Error(t, err)
+
+	_, err = LookupCredential(":0", nil)
+	require.Error(t, err)
+
+	_, err = LookupCredential("root:", nil)
+	require.Error(t, err)
+}
+
+func TestLookupCredentialInRoot(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "etc"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "etc", "passwd"), []byte(
+		"# comment\nroot:x:0:0:root:/root:/bin/sh\napp:x:54321:54320:App:/home/app:/bin/sh\n",
+	), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "etc", "group"), []byte(
+		"root:x:0:\napp:x:54320:\naudio:x:54329:nobody,app\nvideo:x:54328:nobody\n",
+	), 0644))
+
+	cred, err := LookupCredentialInRoot(root, "app", nil)
+	require.NoError(t, err)
+	require.Equal(t, Credential{
+		UID:      54321,
+		GID:      54320,
+		Groups:   []uint32{54320, 54329},
+		Username: "app",
+		Home:     "/home/app",
+	}, cred)
+
+	cred, err = LookupCredentialInRoot(root, "54321:audio", nil)
+	require.NoError(t, err)
+	require.Equal(t, "app", cred.Username)
+	require.Equal(t, uint32(54329), cred.GID)
+	require.Equal(t, []uint32{54329}, cred.Groups)
+
+	cred, err = LookupCredentialInRoot(root, "54322", []string{"video"})
+	require.NoError(t, err)
+	require.Equal(t, Credential{UID: 54322, Groups: []uint32{54328}, Home: "/"}, cred)
+
+	_, err = LookupCredentialInRoot(root, "minit-no-such-user", nil)
+	require.Error(t, err)
+
+	_, err = LookupCredentialInRoot(root, "app:minit-no-such-group", nil)
+	require.Error(t, err)
+
+	_, err = LookupCredentialInRoot(t.TempDir(), "app", nil)
+	require.Error(t, err)
+}
+
+func TestManagerExecuteUser(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("requires root")
+	}
+
+	m := NewManager(ManagerOptions{})
+
+	dir := t.TempDir()
+
+	logger, err := mlog.NewProcLogger(mlog.ProcLoggerOptions{
+		FileOptions: &mlog.RotatingFileOptions{
+			Dir:      dir,
+			Filename: "user",
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:                "once/user",
+		Dir:                 "/",
+		Shell:               "/bin/sh",
+		Command:             []string{`echo "$(id -u):$(id -g):$(id -G):$HOME:$USER"`},
+		User:                "12345:23456",
+		SupplementaryGroups: []string{"34567"},
+		Logger:              logger,
+	})
+	require.NoError(t, err)
+
+	buf, err := os.ReadFile(filepath.Join(dir, "user.out.log"))
+	require.NoError(t, err)
+	require.Contains(t, string(buf), "12345:23456:23456 34567:/:")
+
+	res, err := m.Execute(context.Background(), ExecuteOptions{
+		Name:    "once/user",
+		Command: []string{"true"},
+		User:    "minit-no-such-user",
+		Logger:  logger,
+	})
+	require.Error(t, err)
+	require.False(t, res.Started)
+}
@@ -0,0 +1,66 @@
+package mexec
+
+import (
+	"errors"
+	"os"
+	"strconv"
+)
+
+const (
+	DefaultCreateDirMode os.FileMode = 0755
+)
+
+// CreateDirOptions are options of creating the working directory
+type CreateDirOptions struct {
+	Mode  os.FileMode // default is DefaultCreateDirMode if created, an existing directory is not changed if not set
+	Owner string      // 'user[:group]', see LookupCredentialInRoot, default is ExecuteOptions.User if created
+}
+
+// ParseFileMode parses an octal permission string, e.g. '0750', '022'
+func ParseFileMode(s string) (mode os.FileMode, err error) {
+	var v uint64
+	if v, err = strconv.ParseUint(s, 8, 32); err != nil || v > 0777 {
+		err = errors.New("invalid mode '" + s + "', must be octal within 0000 to 0777")
+		return
+	}
+	mode = os.FileMode(v)
+	return
+}
+
+// createDir creates the directory with parents if missing, mode and owner are applied to the directory itself. An
+// existing directory is only changed by mode and owner set explicitly, e.g. '/tmp' keeps its sticky bit. Names of owner
+// are resolved inside root, see LookupCredentialInRoot.
+func createDir(root string, dir string, opts CreateDirOptions, user string) (err error) {
+	if _, err = os.Stat(dir); err != nil {
+		if !os.IsNotExist(err) {
+			return
+		}
+		if opts.Mode == 0 {
+			opts.Mode = DefaultCreateDirMode
+		}
+		if opts.Owner == "" {
+			opts.Owner = user
+		}
+		if err = os.MkdirAll(dir, opts.Mode); err != nil {
+			return
+		}
+	}
+
+	// MkdirAll is affected by umask
+	if opts.Mode != 0 {
+		if err = os.Chmod(dir, opts.Mode); err != nil {
+			return
+		}
+	}
+
+	if opts.Owner != "" {
+		var cred Credential
+		if cred, err = LookupCredentialInRoot(root, opts.Owner, nil); err != nil {
+			return
+		}
+		if err = os.Chown(dir, int(cred.UID), int(cred.GID)); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,90 @@
+package mexec
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFileMode(t *testing.T) {
+	mode, err := ParseFileMode("0750")
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0750), mode)
+
+	mode, err = ParseFileMode("22")
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(022), mode)
+
+	_, err = ParseFileMode("0780")
+	require.Error(t, err)
+	_, err = ParseFileMode("01777")
+	require.Error(t, err)
+}
+
+func TestCreateDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "b")
+
+	require.NoError(t, createDir("", dir, CreateDirOptions{Mode: 0700}, ""))
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+	require.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	// existing directory is not changed without mode and owner
+	require.NoError(t, createDir("", dir, CreateDirOptions{}, "minit-no-such-user"))
+	info, err = os.Stat(dir)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	// explicit mode is applied to existing directory
+	require.NoError(t, createDir("", dir, CreateDirOptions{Mode: 0750}, ""))
+	info, err = os.Stat(dir)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0750), info.Mode().Perm())
+
+	// sticky bit of shared directory is kept
+	shared := filepath.Join(t.TempDir(), "shared")
+	require.NoError(t, os.Mkdir(shared, 0777))
+	require.NoError(t, os.Chmod(shared, 0777|os.ModeSticky))
+	require.NoError(t, createDir("", shared, CreateDirOptions{}, ""))
+	info, err = os.Stat(shared)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0777)|os.ModeSticky, info.Mode()&(os.ModePerm|os.ModeSticky))
+
+	require.Error(t, createDir("", dir, CreateDirOptions{Owner: "minit-no-such-user"}, ""))
+}
+
+func TestCreateDirOwnerInRoot(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("requires root")
+	}
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "etc"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "etc", "passwd"), []byte("app:x:54321:54320::/home/app:/bin/sh\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "etc", "group"), []byte("app:x:54320:\n"), 0644))
+
+	// default owner is the unit user, resolved inside root
+	dir := filepath.Join(root, "run", "app")
+	require.NoError(t, createDir(root, dir, CreateDirOptions{}, "app"))
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	require.Equal(t, DefaultCreateDirMode, info.Mode().Perm())
+	require.Equal(t, uint32(54321), info.Sys().(*syscall.Stat_t).Uid)
+	require.Equal(t, uint32(54320), info.Sys().(*syscall.Stat_t).Gid)
+
+	// existing directory keeps its owner unless set explicitly
+	require.NoError(t, os.Chown(dir, 0, 0))
+	require.NoError(t, createDir(root, dir, CreateDirOptions{}, "app"))
+	info, err = os.Stat(dir)
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), info.Sys().(*syscall.Stat_t).Uid)
+
+	require.NoError(t, createDir(root, dir, CreateDirOptions{Owner: "app:app"}, ""))
+	info, err = os.Stat(dir)
+	require.NoError(t, err)
+	require.Equal(t, uint32(54321), info.Sys().(*syscall.Stat_t).Uid)
+}
@@ -0,0 +1,59 @@
+package mexec
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+const (
+	// HelperArg is the first argument of minit re-executed as the exec helper, see RunHelper
+	HelperArg = "__minit_exec_helper__"
+
+	helperExe = "/proc/self/exe"
+)
+
+// HelperOptions are applied to the process itself right before executing the command, since go can not
+// run code between fork and exec of a child process
+type HelperOptions struct {
+	Umask            *int        `json:"umask,omitempty"`
+	Chroot           string      `json:"chroot,omitempty"`
+	Dir              string      `json:"dir,omitempty"` // working directory inside chroot
+	Credential       *Credential `json:"credential,omitempty"`
+	Capabilities     []string    `json:"capabilities,omitempty"`      // only these capabilities are kept, raised as ambient capabilities for non-root users
+	DropCapabilities []string    `json:"drop_capabilities,omitempty"` // capabilities removed from bounding set
+	NoNewPrivileges  bool        `json:"no_new_privileges,omitempty"`
+}
+
+// required returns true if options can only be applied by the exec helper
+func (o HelperOptions) required() bool {
+	return o.Umask != nil || o.Chroot != "" || len(o.Capabilities) > 0 || len(o.DropCapabilities) > 0 || o.NoNewPrivileges
+}
+
+// helperArgv wraps argv to be executed by minit itself as the exec helper
+func helperArgv(opts HelperOptions, argv []string) (out []string, err error) {
+	var buf []byte
+	if buf, err = json.Marshal(opts); err != nil {
+		return
+	}
+	out = append([]string{helperExe, HelperArg, string(buf)}, argv...)
+	return
+}
+
+// IsHelper returns true if minit is started as the exec helper
+func IsHelper(args []string) bool {
+	return len(args) > 1 && args[1] == HelperArg
+}
+
+// RunHelper runs the exec helper with arguments after HelperArg, it only returns on failure
+func RunHelper(args []string) (err error) {
+	if len(args) < 2 {
+		return errors.New("exec helper: missing arguments")
+	}
+
+	var opts HelperOptions
+	if err = json.Unmarshal([]byte(args[0]), &opts); err != nil {
+		return errors.New("exec helper: invalid options: " + err.Error())
+	}
+
+	return Exec(opts, args[1:])
+}
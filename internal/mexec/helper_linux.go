@@ -0,0 +1,139 @@
+//go:build linux
+
+package mexec
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Exec applies privileges to the current process and executes argv in place of it, it only returns on failure
+func Exec(opts HelperOptions, argv []string) (err error) {
+	if len(argv) == 0 {
+		return errors.New("missing command")
+	}
+
+	// capabilities and no_new_privs are attributes of thread, exec happens in this thread as well
+	runtime.LockOSThread()
+
+	var keep, drop []int
+	for _, name := range opts.Capabilities {
+		var c int
+		if c, err = ParseCapability(name); err != nil {
+			return
+		}
+		keep = append(keep, c)
+	}
+	for _, name := range opts.DropCapabilities {
+		var c int
+		if c, err = ParseCapability(name); err != nil {
+			return
+		}
+		drop = append(drop, c)
+	}
+
+	if opts.Umask != nil {
+		syscall.Umask(*opts.Umask)
+	}
+
+	// command is looked up and executed inside chroot
+	if opts.Chroot != "" {
+		if err = syscall.Chroot(opts.Chroot); err != nil {
+			return errors.New("failed changing root directory: " + err.Error())
+		}
+		dir := opts.Dir
+		if dir == "" {
+			dir = "/"
+		}
+		if err = syscall.Chdir(dir); err != nil {
+			return errors.New("failed changing working directory: " + err.Error())
+		}
+	}
+
+	allowed := func(c int) bool {
+		return (len(keep) == 0 || slices.Contains(keep, c)) && !slices.Contains(drop, c)
+	}
+
+	// bounding set limits capabilities gained by exec, even for root
+	if len(keep) > 0 || len(drop) > 0 {
+		for c := 0; c <= lastCapability(); c++ {
+			if allowed(c) {
+				continue
+			}
+			if err = unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0); err != nil {
+				return errors.New("failed dropping capabilities from bounding set: " + err.Error())
+			}
+		}
+	}
+
+	if cred := opts.Credential; cred != nil {
+		// keep permitted capabilities across setuid, to raise ambient capabilities later
+		if len(keep) > 0 {
+			if err = unix.Prctl(unix.PR_SET_KEEPCAPS, 1, 0, 0, 0); err != nil {
+				return errors.New("failed keeping capabilities: " + err.Error())
+			}
+		}
+
+		groups := []int{}
+		for _, gid := range cred.Groups {
+			groups = append(groups, int(gid))
+		}
+		if err = syscall.Setgroups(groups); err != nil {
+			return errors.New("failed setting supplementary groups: " + err.Error())
+		}
+		if err = syscall.Setgid(int(cred.GID)); err != nil {
+			return errors.New("failed setting gid: " + err.Error())
+		}
+		if err = syscall.Setuid(int(cred.UID)); err != nil {
+			return errors.New("failed setting uid: " + err.Error())
+		}
+	}
+
+	if len(keep) > 0 || len(drop) > 0 {
+		hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+		var data [2]unix.CapUserData
+		if err = unix.Capget(&hdr, &data[0]); err != nil {
+			return errors.New("failed getting capabilities: " + err.Error())
+		}
+		for c := 0; c < 64; c++ {
+			mask := uint32(1) << (c % 32)
+			if !allowed(c) {
+				data[c/32].Inheritable &^= mask
+			} else if slices.Contains(keep, c) {
+				data[c/32].Inheritable |= mask
+			}
+		}
+		for i := range data {
+			data[i].Effective = data[i].Permitted
+		}
+		if err = unix.Capset(&hdr, &data[0]); err != nil {
+			return errors.New("failed setting capabilities: " + err.Error())
+		}
+
+		// ambient capabilities are preserved by exec of non-privileged programs, e.g. after switching user
+		for _, c := range keep {
+			if err = unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_RAISE, uintptr(c), 0, 0); err != nil {
+				return errors.New("failed raising ambient capabilities: " + err.Error())
+			}
+		}
+	}
+
+	if opts.NoNewPrivileges {
+		if err = unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+			return errors.New("failed setting no_new_privs: " + err.Error())
+		}
+	}
+
+	var path string
+	if path, err = exec.LookPath(argv[0]); err != nil {
+		return
+	}
+
+	return syscall.Exec(path, argv, os.Environ())
+}
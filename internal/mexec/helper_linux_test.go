@@ -0,0 +1,189 @@
+//go:build linux
+
+package mexec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yankeguo/minit/internal/mlog"
+)
+
+func TestMain(m *testing.M) {
+	// test binary is re-executed as the exec helper
+	if IsHelper(os.Args) {
+		if err := RunHelper(os.Args[2:]); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+		}
+		os.Exit(1)
+	}
+	// test binary is executed as su-exec, or with 'exec' like minit
+	if IsSuExec(os.Args) || (len(os.Args) > 1 && os.Args[1] == "exec") {
+		var err error
+		if IsSuExec(os.Args) {
+			err = RunSuExec(os.Args[1:])
+		} else {
+			err = RunExec(os.Args[2:])
+		}
+		_, _ = fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+func TestParseCapability(t *testing.T) {
+	c, err := ParseCapability("net_bind_service")
+	require.NoError(t, err)
+	require.Equal(t, 10, c)
+
+	c, err = ParseCapability("CAP_SYS_ADMIN")
+	require.NoError(t, err)
+	require.Equal(t, 21, c)
+
+	_, err = ParseCapability("CAP_EVERYTHING")
+	require.Error(t, err)
+
+	require.NoError(t, ValidateCapabilities([]string{"chown", "CAP_KILL"}))
+	require.Error(t, ValidateCapabilities([]string{"chown", "kill_all"}))
+}
+
+func TestManagerExecuteHelper(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("requires root")
+	}
+
+	m := NewManager(ManagerOptions{})
+
+	dir := t.TempDir()
+
+	logger, err := mlog.NewProcLogger(mlog.ProcLoggerOptions{
+		FileOptions: &mlog.RotatingFileOptions{
+			Dir:      dir,
+			Filename: "helper",
+		},
+	})
+	require.NoError(t, err)
+
+	// read returns output since last read
+	var offset int
+	read := func() string {
+		buf, err := os.ReadFile(filepath.Join(dir, "helper.out.log"))
+		require.NoError(t, err)
+		out := string(buf[offset:])
+		offset = len(buf)
+		return out
+	}
+
+	// non-root user with a single capability
+	_, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:            "daemon/helper",
+		Dir:             "/",
+		Command:         []string{"grep", "-E", "^(Uid|CapEff|CapBnd|CapAmb|NoNewPrivs)", "/proc/self/status"},
+		User:            "12345",
+		Capabilities:    []string{"NET_BIND_SERVICE"},
+		NoNewPrivileges: true,
+		Logger:          logger,
+	})
+	require.NoError(t, err)
+
+	out := read()
+	require.Regexp(t, `Uid:\s+12345`, out)
+	require.Regexp(t, `CapEff:\s+0000000000000400`, out)
+	require.Regexp(t, `CapBnd:\s+0000000000000400`, out)
+	require.Regexp(t, `CapAmb:\s+0000000000000400`, out)
+	require.Regexp(t, `NoNewPrivs:\s+1`, out)
+
+	// root without CAP_CHOWN
+	_, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:             "daemon/helper",
+		Command:          []string{"chown", "12345", dir},
+		DropCapabilities: []string{"chown"},
+		Logger:           logger,
+	})
+	require.Error(t, err)
+
+	// umask
+	umask := 027
+	_, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:    "daemon/helper",
+		Shell:   "/bin/sh",
+		Command: []string{"umask"},
+		Umask:   &umask,
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+	require.Contains(t, read(), "0027")
+
+	// chroot, working directory is inside the new root, and created on demand
+	work := filepath.Join(dir, "work")
+	_, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:      "daemon/helper",
+		Chroot:    "/",
+		Dir:       work,
+		CreateDir: &CreateDirOptions{Mode: 0700, Owner: "12345:23456"},
+		Command:   []string{"pwd"},
+		Logger:    logger,
+	})
+	require.NoError(t, err)
+	require.Contains(t, read(), work)
+
+	info, err := os.Stat(work)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0700), info.Mode().Perm())
+	require.Equal(t, uint32(12345), info.Sys().(*syscall.Stat_t).Uid)
+	require.Equal(t, uint32(23456), info.Sys().(*syscall.Stat_t).Gid)
+
+	// failures of exec helper
+	_, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:            "daemon/helper",
+		Command:         []string{"minit-no-such-command"},
+		NoNewPrivileges: true,
+		Logger:          logger,
+	})
+	require.Error(t, err)
+}
+
+func TestSuExec(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("requires root")
+	}
+
+	self, err := os.Executable()
+	require.NoError(t, err)
+
+	alias := filepath.Join(t.TempDir(), SuExecName)
+	require.NoError(t, os.Symlink(self, alias))
+
+	script := `echo "$(id -u):$(id -g):$(id -G):$HOME"`
+
+	out, err := exec.Command(self, "exec", "--user", "54321", "--group", "54320", "--groups", "10,11", "--", "sh", "-c", script).CombinedOutput()
+	require.NoError(t, err, string(out))
+	require.Equal(t, "54321:54320:54320 10 11:/\n", string(out))
+
+	out, err = exec.Command(alias, "0:0", "sh", "-c", script).CombinedOutput()
+	require.NoError(t, err, string(out))
+	require.Contains(t, string(out), "0:0:")
+
+	// groups of the user are dropped if group is given, or user is unknown
+	out, err = exec.Command(alias, "root:54320", "sh", "-c", script).CombinedOutput()
+	require.NoError(t, err, string(out))
+	require.Equal(t, "0:54320:54320:/root\n", string(out))
+
+	out, err = exec.Command(alias, "54321:54320", "sh", "-c", script).CombinedOutput()
+	require.NoError(t, err, string(out))
+	require.Equal(t, "54321:54320:54320:/\n", string(out))
+
+	out, err = exec.Command(alias, "54321", "sh", "-c", script).CombinedOutput()
+	require.NoError(t, err, string(out))
+	require.Equal(t, "54321:0:0:/\n", string(out))
+
+	out, err = exec.Command(alias, "no-such-user", "true").CombinedOutput()
+	require.Error(t, err)
+	require.Contains(t, string(out), "no-such-user")
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package mexec
+
+import "errors"
+
+// Exec is only supported on linux
+func Exec(opts HelperOptions, argv []string) error {
+	return errors.New("exec helper is not supported on this platform")
+}
@@ -1,13 +1,19 @@
 package mexec
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/yankeguo/minit/internal/menv"
 	"github.com/yankeguo/minit/internal/mlog"
@@ -16,22 +22,164 @@ import (
 	"golang.org/x/text/encoding/simplifiedchinese"
 )
 
+const (
+	streamDrainTimeout = time.Second
+
+	DefaultStopTimeout = time.Second * 10
+)
+
+const (
+	KillModeProcess = "process" // signals are sent to the main process only
+	KillModeGroup   = "group"   // signals are sent to the whole process group
+	KillModeMixed   = "mixed"   // stop signal is sent to the main process, SIGKILL to the whole process group
+)
+
 type ExecuteOptions struct {
 	Name string
 
-	Dir          string
+	Dir          string            // working directory, inside Chroot if set
+	CreateDir    *CreateDirOptions // create Dir if set
+	Chroot       string            // root directory of the process
+	Umask        *int              // umask of the process, default is the umask of minit
 	Shell        string
 	Env          map[string]string
 	Command      []string
 	Charset      string
 	SuccessCodes []int
 
+	StopSignal  os.Signal     // signal to stop the process, if nil, the signal passed to Stop is used
+	StopTimeout time.Duration // time to wait before killing the process on Stop, default is DefaultStopTimeout
+	KillMode    string        // one of KillModeProcess (default), KillModeGroup and KillModeMixed
+	PIDFile     string        // pid file of a forking daemon, processes left in the group are not killed on exit
+
+	User                string   // 'user[:group]' to run the process as, names or numeric IDs, see LookupCredentialInRoot
+	SupplementaryGroups []string // supplementary groups, see LookupCredential for defaults
+
+	Capabilities     []string // only these capabilities are kept, e.g. CAP_NET_BIND_SERVICE, see HelperOptions
+	DropCapabilities []string // capabilities removed from bounding set
+	NoNewPrivileges  bool     // set no_new_privs, setuid binaries and file capabilities no longer grant privileges
+
+	RLimits map[string]string // rlimits of the process, e.g. 'nofile' to '65536:65536', applied right after started
+	Cgroup  CgroupOptions     // cgroup v2 resource controls, the process is moved into its own cgroup right after started
+	Sched   SchedOptions      // nice, ionice, cpu affinity and oom_score_adj, applied right after started
+
 	Logger mlog.ProcLogger
 }
 
+// ExecuteResult is the result of a finished process
+type ExecuteResult struct {
+	Started    bool           `json:"started"`     // false if the process failed to start
+	ExitCode   int            `json:"exit_code"`   // exit code of the process, -1 if killed by signal or unknown
+	Signal     syscall.Signal `json:"signal"`      // signal killed the process, 0 if exited normally
+	CoreDumped bool           `json:"core_dumped"` // true if the process dumped core when killed by signal
+	OOMKilled  bool           `json:"oom_killed"`  // true if the process was killed by the OOM killer, detected with memory.events of cgroup v2
+	Duration   time.Duration  `json:"duration"`    // wall-clock time from started to exited
+	UserTime   time.Duration  `json:"user_time"`   // user CPU time, including waited children of the process
+	SystemTime time.Duration  `json:"system_time"` // system CPU time, including waited children of the process
+	MaxRSS     int64          `json:"max_rss"`     // maximum resident set size in bytes
+}
+
+// ExitStatus returns the exit code, or 128+signal if the process was killed by a signal, like shells do
+func (r ExecuteResult) ExitStatus() int {
+	if r.Signal != 0 {
+		return 128 + int(r.Signal)
+	}
+	return r.ExitCode
+}
+
+// String returns a one-line summary, e.g. 'exit code 0, duration 1.2s, user 800ms, system 100ms, max rss 12.5MiB'
+func (r ExecuteResult) String() string {
+	var status string
+	if r.Signal != 0 {
+		status = "signal " + signalName(r.Signal)
+		if r.CoreDumped {
+			status += " (core dumped)"
+		}
+		if r.OOMKilled {
+			status += " (oom killed)"
+		}
+	} else {
+		status = "exit code " + strconv.Itoa(r.ExitCode)
+	}
+	return fmt.Sprintf(
+		"%s, duration %s, user %s, system %s, max rss %s",
+		status,
+		r.Duration.Round(time.Millisecond),
+		r.UserTime.Round(time.Millisecond),
+		r.SystemTime.Round(time.Millisecond),
+		formatBytes(r.MaxRSS),
+	)
+}
+
+// formatBytes formats bytes in binary units, e.g. '12.5MiB'
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + "B"
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit && exp < 4; v /= unit {
+		div *= unit
+		exp++
+	}
+	return strconv.FormatFloat(float64(n)/float64(div), 'f', 1, 64) + string("KMGTP"[exp]) + "iB"
+}
+
 type Manager interface {
+	// Signal sends signal to all managed processes
 	Signal(sig os.Signal)
-	Execute(opts ExecuteOptions) (err error)
+	// SignalName sends signal to managed processes started with the given ExecuteOptions.Name
+	SignalName(name string, sig os.Signal)
+	// Stop sends stop signal to managed processes started with the given ExecuteOptions.Name, and kills them if
+	// not exited within stop timeout, sig is used if no ExecuteOptions.StopSignal was set,
+	// returned channel is closed once all of them exited
+	Stop(name string, sig os.Signal) <-chan struct{}
+	// StopAll is like Stop, but for all managed processes
+	StopAll(sig os.Signal) <-chan struct{}
+	// PIDs returns pids of managed processes started with the given ExecuteOptions.Name
+	PIDs(name string) []int
+	// Execute starts a process and waits for it to exit, once ctx is done, the process is stopped like Stop with
+	// SIGTERM if no ExecuteOptions.StopSignal was set, and err reports the cause
+	Execute(ctx context.Context, opts ExecuteOptions) (res ExecuteResult, err error)
+	// Watch is like Execute, but tracks a running process not started by Manager, e.g. a daemon forked by a launcher,
+	// options applied on starting are ignored. Exit status is only known if the process is a child of minit, e.g. an
+	// orphan adopted by minit, otherwise ExitCode is -1 and err is not nil once the process disappeared.
+	Watch(ctx context.Context, opts ExecuteOptions, pid int) (res ExecuteResult, err error)
+}
+
+// managedProcess is a started process tracked by manager
+type managedProcess struct {
+	name        string
+	process     *os.Process
+	stopSignal  os.Signal
+	stopTimeout time.Duration
+	killMode    string
+	pgid        int // process group signaled in group kill modes, the process is signaled alone if 0
+	logger      mlog.ProcLogger
+	done        chan struct{} // closed once process exited
+	stopOnce    sync.Once
+}
+
+// signal sends signal to the process, or to the whole process group in KillModeGroup
+func (mp *managedProcess) signal(sig os.Signal) {
+	if mp.killMode == KillModeGroup && mp.pgid != 0 {
+		_ = signalGroup(mp.pgid, sig)
+		return
+	}
+	_ = mp.process.Signal(sig)
+}
+
+// kill kills the process, and the whole process group unless in KillModeProcess
+func (mp *managedProcess) kill() {
+	if isGroupKillMode(mp.killMode) && mp.pgid != 0 {
+		_ = signalGroup(mp.pgid, syscall.SIGKILL)
+		return
+	}
+	_ = mp.process.Kill()
+}
+
+func isGroupKillMode(mode string) bool {
+	return mode == KillModeGroup || mode == KillModeMixed
 }
 
 // manager implements Manager interface with thread-safe process tracking
@@ -40,36 +188,77 @@ type Manager interface {
 // - StartCommand and Signal both acquire lock to ensure atomicity
 // - charsets map is read-only after initialization, no locking needed
 type manager struct {
-	managedPIDs    map[int]struct{}             // Protected by managedPIDLock
+	managedPIDs    map[int]*managedProcess      // Protected by managedPIDLock
 	managedPIDLock sync.Locker                  // Protects managedPIDs map
 	charsets       map[string]encoding.Encoding // Read-only after init
+	cgroups        *cgroupManager               // Has its own lock
+}
+
+type ManagerOptions struct {
+	CgroupRoot string // root of cgroup v2 hierarchy, default is DefaultCgroupRoot, CgroupDisabled disables cgroup controls
 }
 
-func NewManager() Manager {
+func NewManager(opts ManagerOptions) Manager {
+	if opts.CgroupRoot == "" {
+		opts.CgroupRoot = DefaultCgroupRoot
+	}
+
 	return &manager{
-		managedPIDs:    map[int]struct{}{},
+		managedPIDs:    map[int]*managedProcess{},
 		managedPIDLock: &sync.Mutex{},
 		charsets: map[string]encoding.Encoding{
 			"gb18030": simplifiedchinese.GB18030,
 			"gbk":     simplifiedchinese.GBK,
 		},
+		cgroups: newCgroupManager(opts.CgroupRoot),
 	}
 }
 
-func (m *manager) StartCommand(cmd *exec.Cmd) (done func(), err error) {
+func (m *manager) StartCommand(cmd *exec.Cmd, opts ExecuteOptions) (mp *managedProcess, wait func() (processExit, error), done func(), err error) {
 	m.managedPIDLock.Lock()
 	defer m.managedPIDLock.Unlock()
 
-	if err = cmd.Start(); err != nil {
+	if wait, err = startCommand(cmd); err != nil {
 		return
 	}
 
-	pid := cmd.Process.Pid
-	m.managedPIDs[pid] = struct{}{}
+	mp, done = m.manage(cmd.Process, opts)
+	return
+}
+
+// manage tracks the process until done is called, managedPIDLock must be held
+func (m *manager) manage(process *os.Process, opts ExecuteOptions) (mp *managedProcess, done func()) {
+	mp = &managedProcess{
+		name:        opts.Name,
+		process:     process,
+		stopSignal:  opts.StopSignal,
+		stopTimeout: opts.StopTimeout,
+		killMode:    opts.KillMode,
+		pgid:        process.Pid,
+		logger:      opts.Logger,
+		done:        make(chan struct{}),
+	}
+	if mp.stopTimeout <= 0 {
+		mp.stopTimeout = DefaultStopTimeout
+	}
+
+	pid := process.Pid
+	m.managedPIDs[pid] = mp
 	done = func() {
 		m.managedPIDLock.Lock()
 		defer m.managedPIDLock.Unlock()
 		delete(m.managedPIDs, pid)
+		close(mp.done)
+	}
+	return
+}
+
+// collect returns managed processes matching the name, empty name matches all
+func (m *manager) collect(name string) (mps []*managedProcess) {
+	for _, mp := range m.managedPIDs {
+		if name == "" || mp.name == name {
+			mps = append(mps, mp)
+		}
 	}
 	return
 }
@@ -80,20 +269,200 @@ func (m *manager) Signal(sig os.Signal) {
 
 	// Broadcast signal to all managed processes atomically
 	// Lock ensures no processes are added/removed during broadcast
-	for pid := range m.managedPIDs {
-		if process, _ := os.FindProcess(pid); process != nil {
-			_ = process.Signal(sig)
+	for _, mp := range m.collect("") {
+		if sig == syscall.SIGKILL {
+			mp.kill()
+		} else {
+			mp.signal(sig)
+		}
+	}
+}
+
+func (m *manager) SignalName(name string, sig os.Signal) {
+	m.managedPIDLock.Lock()
+	defer m.managedPIDLock.Unlock()
+
+	for _, mp := range m.collect(name) {
+		mp.signal(sig)
+	}
+}
+
+func (m *manager) PIDs(name string) (pids []int) {
+	m.managedPIDLock.Lock()
+	defer m.managedPIDLock.Unlock()
+
+	for _, mp := range m.collect(name) {
+		pids = append(pids, mp.process.Pid)
+	}
+	sort.Ints(pids)
+	return
+}
+
+func (m *manager) Stop(name string, sig os.Signal) <-chan struct{} {
+	m.managedPIDLock.Lock()
+	defer m.managedPIDLock.Unlock()
+
+	return m.stop(m.collect(name), sig)
+}
+
+func (m *manager) StopAll(sig os.Signal) <-chan struct{} {
+	m.managedPIDLock.Lock()
+	defer m.managedPIDLock.Unlock()
+
+	return m.stop(m.collect(""), sig)
+}
+
+func (m *manager) stop(mps []*managedProcess, sig os.Signal) <-chan struct{} {
+	wg := &sync.WaitGroup{}
+
+	for _, mp := range mps {
+		mp.stop(sig)
+
+		wg.Add(1)
+		go func(mp *managedProcess) {
+			defer wg.Done()
+			<-mp.done
+		}(mp)
+	}
+
+	ch := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+	return ch
+}
+
+// stop sends stop signal to the process, and kills it if not exited within stop timeout, only the first call takes
+// effect, e.g. Stop and cancellation of Execute
+func (mp *managedProcess) stop(sig os.Signal) {
+	mp.stopOnce.Do(func() {
+		if mp.stopSignal != nil {
+			sig = mp.stopSignal
+		}
+
+		mp.signal(sig)
+
+		go func() {
+			timer := time.NewTimer(mp.stopTimeout)
+			defer timer.Stop()
+
+			select {
+			case <-mp.done:
+			case <-timer.C:
+				mp.logger.Errorf("minit: %s: process did not exit in %s after %s, killing", mp.name, mp.stopTimeout, sig)
+				mp.kill()
+			}
+		}()
+	})
+}
+
+// Command returns a command executing argv literally with user, environment, working directory, capabilities,
+// no_new_privileges, umask and chroot of opts, like a process started by Execute, e.g. for exec probes of a unit.
+// Resource controls are not applied, run it with RunCommand.
+func Command(opts ExecuteOptions, argv []string) (cmd *exec.Cmd, err error) {
+	var cred *Credential
+	if cred, err = resolveCredential(opts); err != nil {
+		return
+	}
+
+	var env map[string]string
+	if env, err = buildEnv(opts, cred); err != nil {
+		return
+	}
+
+	return buildCommand(opts, cred, env, argv)
+}
+
+// resolveCredential resolves opts.User inside opts.Chroot, nil if opts.User is not set
+func resolveCredential(opts ExecuteOptions) (cred *Credential, err error) {
+	if opts.User != "" {
+		var c Credential
+		if c, err = LookupCredentialInRoot(opts.Chroot, opts.User, opts.SupplementaryGroups); err != nil {
+			err = errors.New("failed resolving opts.User: " + err.Error())
+			return
+		}
+		cred = &c
+	}
+	return
+}
+
+// buildEnv constructs environment variables of opts, HOME and USER follow the credential
+func buildEnv(opts ExecuteOptions, cred *Credential) (env map[string]string, err error) {
+	sys := menv.Environ()
+	if cred != nil {
+		sys["HOME"] = cred.Home
+		if cred.Username != "" {
+			sys["USER"] = cred.Username
+		} else {
+			delete(sys, "USER")
+		}
+	}
+
+	if env, err = menv.Construct(sys, opts.Env); err != nil {
+		err = errors.New("failed constructing environment variables: " + err.Error())
+	}
+	return
+}
+
+// buildCommand builds exec.Cmd of argv, privileges are applied with exec helper if required
+func buildCommand(opts ExecuteOptions, cred *Credential, env map[string]string, argv []string) (cmd *exec.Cmd, err error) {
+	// privileges can only be applied between fork and exec, minit is re-executed as exec helper to do that
+	helper := HelperOptions{
+		Umask:            opts.Umask,
+		Chroot:           opts.Chroot,
+		Capabilities:     opts.Capabilities,
+		DropCapabilities: opts.DropCapabilities,
+		NoNewPrivileges:  opts.NoNewPrivileges,
+	}
+	if helper.Chroot != "" {
+		helper.Dir = opts.Dir
+	}
+	if helper.required() {
+		helper.Credential, cred = cred, nil
+		if argv, err = helperArgv(helper, argv); err != nil {
+			return
 		}
 	}
+
+	// build exec.Cmd
+	cmd = exec.Command(argv[0], argv[1:]...)
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	if helper.Chroot == "" {
+		cmd.Dir = opts.Dir
+	}
+	setSysProcAttr(cmd)
+	if cred != nil {
+		cmd.SysProcAttr.Credential = &syscall.Credential{
+			Uid:    cred.UID,
+			Gid:    cred.GID,
+			Groups: cred.Groups,
+		}
+	}
+
+	return
 }
 
-func (m *manager) Execute(opts ExecuteOptions) (err error) {
+func (m *manager) Execute(ctx context.Context, opts ExecuteOptions) (res ExecuteResult, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
 	var argv []string
 
 	// check opts.Dir
 	if opts.Dir != "" {
+		dir := filepath.Join(opts.Chroot, opts.Dir)
+		if opts.CreateDir != nil {
+			if err = createDir(opts.Chroot, dir, *opts.CreateDir, opts.User); err != nil {
+				err = errors.New("failed to create opts.Dir: " + err.Error())
+				return
+			}
+		}
 		var info os.FileInfo
-		if info, err = os.Stat(opts.Dir); err != nil {
+		if info, err = os.Stat(dir); err != nil {
 			err = errors.New("failed to stat opts.Dir: " + err.Error())
 			return
 		}
@@ -103,10 +472,14 @@ func (m *manager) Execute(opts ExecuteOptions) (err error) {
 		}
 	}
 
-	// build env
+	// resolve credential, names are resolved inside chroot
+	var cred *Credential
+	if cred, err = resolveCredential(opts); err != nil {
+		return
+	}
+
 	var env map[string]string
-	if env, err = menv.Construct(menv.Environ(), opts.Env); err != nil {
-		err = errors.New("failed constructing environment variables: " + err.Error())
+	if env, err = buildEnv(opts, cred); err != nil {
 		return
 	}
 
@@ -124,25 +497,38 @@ func (m *manager) Execute(opts ExecuteOptions) (err error) {
 		}
 	}
 
-	// build exec.Cmd
-	var outPipe, errPipe io.Reader
-	cmd := exec.Command(argv[0], argv[1:]...)
-	if opts.Shell != "" {
-		cmd.Stdin = strings.NewReader(strings.Join(opts.Command, "\n"))
-	}
-	for k, v := range env {
-		cmd.Env = append(cmd.Env, k+"="+v)
+	var cmd *exec.Cmd
+	if cmd, err = buildCommand(opts, cred, env, argv); err != nil {
+		return
 	}
-	cmd.Dir = opts.Dir
-	setSysProcAttr(cmd)
 
-	// build out / err pipe
-	if outPipe, err = cmd.StdoutPipe(); err != nil {
+	// build out / err pipe, pipes are created manually, so that output can be fully consumed after process exited
+	var outR, outW, errR, errW *os.File
+	if outR, outW, err = os.Pipe(); err != nil {
 		return
 	}
-	if errPipe, err = cmd.StderrPipe(); err != nil {
+	if errR, errW, err = os.Pipe(); err != nil {
+		outR.Close()
+		outW.Close()
 		return
 	}
+	cmd.Stdout = outW
+	cmd.Stderr = errW
+
+	// script is written to stdin of shell through a manual pipe as well, exec.Cmd.Wait is not used with the reaper
+	var inR, inW *os.File
+	if opts.Shell != "" {
+		if inR, inW, err = os.Pipe(); err != nil {
+			outR.Close()
+			outW.Close()
+			errR.Close()
+			errW.Close()
+			return
+		}
+		cmd.Stdin = inR
+	}
+
+	var outPipe, errPipe io.Reader = outR, errR
 
 	// charset
 	if opts.Charset != "" {
@@ -155,33 +541,250 @@ func (m *manager) Execute(opts ExecuteOptions) (err error) {
 		}
 	}
 
+	// oom_kill counter of the cgroup processes without their own cgroup are in, replaced once cgroup applied
+	oomBefore := m.cgroups.oomKills("")
+
 	// start process in the same lock with signal children
-	var done func()
-	if done, err = m.StartCommand(cmd); err != nil {
+	var (
+		mp   *managedProcess
+		wait func() (processExit, error)
+		done func()
+	)
+	mp, wait, done, err = m.StartCommand(cmd, opts)
+
+	// write ends are owned by child process now
+	outW.Close()
+	errW.Close()
+	if inR != nil {
+		inR.Close()
+	}
+
+	if err != nil {
+		outR.Close()
+		errR.Close()
+		if inW != nil {
+			inW.Close()
+		}
 		return
 	}
-	defer done()
+
+	if inW != nil {
+		go func() {
+			defer inW.Close()
+			_, _ = io.WriteString(inW, strings.Join(opts.Command, "\n"))
+		}()
+	}
+
+	res.Started = true
+
+	startedAt := time.Now()
+
+	// stop the process once ctx is done
+	cancelStop := context.AfterFunc(ctx, func() {
+		mp.stop(syscall.SIGTERM)
+	})
+
+	if len(opts.RLimits) > 0 {
+		if err := setRLimits(cmd.Process.Pid, opts.RLimits); err != nil {
+			opts.Logger.Errorf("minit: %s: failed setting rlimits: %s", opts.Name, err.Error())
+		}
+	}
+
+	if err := setSched(cmd.Process.Pid, opts.Sched); err != nil {
+		opts.Logger.Errorf("minit: %s: failed setting scheduling options: %s", opts.Name, err.Error())
+	}
+
+	var (
+		cgroupDir     string
+		cgroupApplied bool
+	)
+	if !opts.Cgroup.IsZero() {
+		var (
+			err    error
+			before oomCounter
+		)
+		if cgroupDir, before, err = m.cgroups.apply(opts.Name, cmd.Process.Pid, opts.Cgroup); err != nil {
+			opts.Logger.Errorf("minit: %s: failed applying cgroup: %s", opts.Name, err.Error())
+		} else {
+			cgroupApplied = true
+			oomBefore = before
+		}
+	}
 
 	// streaming
-	go opts.Logger.Out().ReadFrom(outPipe)
-	go opts.Logger.Err().ReadFrom(errPipe)
+	wgStream := &sync.WaitGroup{}
+	wgStream.Add(2)
+	go func() {
+		defer wgStream.Done()
+		defer outR.Close()
+		_, _ = opts.Logger.Out().ReadFrom(outPipe)
+	}()
+	go func() {
+		defer wgStream.Done()
+		defer errR.Close()
+		_, _ = opts.Logger.Err().ReadFrom(errPipe)
+	}()
 
 	// wait for process
-	err = cmd.Wait()
+	var pe processExit
+	pe, err = wait()
+
+	res.Duration = time.Since(startedAt)
+
+	// false if the process was stopped by ctx
+	stopped := !cancelStop()
+
+	// clean up remaining processes in the group, e.g. background jobs of a shell, unless the command is a launcher
+	// expected to leave a forking daemon behind
+	if isGroupKillMode(opts.KillMode) && opts.PIDFile == "" {
+		_ = signalGroup(cmd.Process.Pid, syscall.SIGKILL)
+	}
+
+	done()
+
+	// counter of the own cgroup must be read before released
+	if err == nil {
+		var oomDir string
+		if cgroupApplied {
+			oomDir = cgroupDir
+		}
+		res.OOMKilled = m.checkOOMKilled(pe, oomDir, oomBefore)
+	}
+
+	if cgroupDir != "" {
+		m.cgroups.release(cgroupDir)
+	}
+
+	// wait for remaining output, background children may hold the pipes, so don't wait forever
+	waitGroupTimeout(wgStream, streamDrainTimeout)
+
+	if err != nil {
+		opts.Logger.Errorf("minit: %s: failed waiting for process: %s", opts.Name, err.Error())
+		return
+	}
+
+	err = complete(ctx, opts, &res, pe, stopped)
+	return
+}
+
+func (m *manager) Watch(ctx context.Context, opts ExecuteOptions, pid int) (res ExecuteResult, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	var process *os.Process
+	if process, err = os.FindProcess(pid); err != nil {
+		return
+	}
+	defer process.Release()
+
+	var (
+		mp   *managedProcess
+		wait func() (processExit, bool)
+		done func()
+	)
+
+	// the process inherited the own cgroup of the launcher, which was left over since not empty
+	var cgroupDir string
+	if !opts.Cgroup.IsZero() {
+		cgroupDir = m.cgroups.lookup(opts.Name)
+	}
+
+	oomBefore := m.cgroups.oomKills(cgroupDir)
 
-	var code int
+	// register in the same lock with signal children, like StartCommand
+	m.managedPIDLock.Lock()
+	if wait, err = watchProcess(pid); err == nil {
+		mp, done = m.manage(process, opts)
+		// a forked daemon is usually not a group leader, its group is looked up
+		mp.pgid = processGroup(pid)
+	}
+	m.managedPIDLock.Unlock()
 
 	if err != nil {
-		opts.Logger.Errorf("minit: %s: process exited with error: %s", opts.Name, err.Error())
-		if ee, ok := err.(*exec.ExitError); ok {
-			code = ee.ExitCode()
+		err = errors.New("failed watching process " + strconv.Itoa(pid) + ": " + err.Error())
+		return
+	}
+
+	res.Started = true
+
+	startedAt := time.Now()
+
+	// stop the process once ctx is done
+	cancelStop := context.AfterFunc(ctx, func() {
+		mp.stop(syscall.SIGTERM)
+	})
+
+	pe, ok := wait()
+
+	res.Duration = time.Since(startedAt)
+
+	// false if the process was stopped by ctx
+	stopped := !cancelStop()
+
+	// clean up remaining processes in the group
+	if isGroupKillMode(opts.KillMode) && mp.pgid != 0 {
+		_ = signalGroup(mp.pgid, syscall.SIGKILL)
+	}
+
+	done()
+
+	if ok {
+		res.OOMKilled = m.checkOOMKilled(pe, cgroupDir, oomBefore)
+	}
+
+	if cgroupDir != "" {
+		m.cgroups.release(cgroupDir)
+	}
+
+	if !ok {
+		res.ExitCode = -1
+		if stopped {
+			err = errors.New("process stopped: " + context.Cause(ctx).Error())
 		} else {
-			return
+			err = errors.New("process " + strconv.Itoa(pid) + " exited, exit status is unknown")
 		}
+		opts.Logger.Errorf("minit: %s: %s", opts.Name, err.Error())
+		return
+	}
+
+	err = complete(ctx, opts, &res, pe, stopped)
+	return
+}
+
+// checkOOMKilled returns true if the process was killed by the OOM killer, with SIGKILL and increase of the oom_kill
+// counter of its own cgroup dir, or of the cgroup processes without their own cgroup are in if dir is empty, counters
+// of different cgroups are not comparable, e.g. minit was moved into the init leaf in the meantime
+func (m *manager) checkOOMKilled(pe processExit, dir string, before oomCounter) bool {
+	if !pe.status.Signaled() || pe.status.Signal() != syscall.SIGKILL {
+		return false
+	}
+	after := m.cgroups.oomKills(dir)
+	return before.ok && after.ok && after.dir == before.dir && after.count > before.count
+}
+
+// complete fills res with the exit status, logs it, and checks it against success codes, or returns the cause if the
+// process was stopped by ctx
+func complete(ctx context.Context, opts ExecuteOptions, res *ExecuteResult, pe processExit, stopped bool) (err error) {
+	res.ExitCode = pe.status.ExitStatus()
+	if pe.status.Signaled() {
+		res.Signal = pe.status.Signal()
+		res.CoreDumped = pe.status.CoreDump()
+	}
+	res.UserTime, res.SystemTime = pe.cpuTimes()
+	res.MaxRSS = pe.maxRSS()
+
+	opts.Logger.Printf("minit: %s: process exited: %s", opts.Name, res.String())
+
+	code := res.ExitCode
+
+	if stopped {
+		err = errors.New("process stopped: " + context.Cause(ctx).Error())
+		opts.Logger.Errorf("minit: %s: %s", opts.Name, err.Error())
+		return
 	}
 
 	if checkSuccessCode(opts.SuccessCodes, code) {
-		err = nil
 		return
 	}
 
@@ -192,6 +795,22 @@ func (m *manager) Execute(opts ExecuteOptions) (err error) {
 	return
 }
 
+func waitGroupTimeout(wg *sync.WaitGroup, timeout time.Duration) {
+	ch := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-ch:
+	case <-timer.C:
+	}
+}
+
 func checkSuccessCode(successCodes []int, code int) bool {
 	if len(successCodes) == 0 {
 		return code == 0
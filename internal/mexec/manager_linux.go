@@ -3,6 +3,8 @@
 package mexec
 
 import (
+	"errors"
+	"os"
 	"os/exec"
 	"syscall"
 )
@@ -12,3 +14,22 @@ func setSysProcAttr(cmd *exec.Cmd) {
 		Setpgid: true,
 	}
 }
+
+// signalGroup sends signal to the process group pgid
+func signalGroup(pgid int, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return errors.New("unsupported signal type")
+	}
+	return syscall.Kill(-pgid, s)
+}
+
+// processGroup returns the process group of pid, or 0 if unknown or it is the group of minit itself, which must not be
+// signaled as a whole
+func processGroup(pid int) int {
+	pgid, err := syscall.Getpgid(pid)
+	if err != nil || pgid == syscall.Getpgrp() {
+		return 0
+	}
+	return pgid
+}
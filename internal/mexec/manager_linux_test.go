@@ -0,0 +1,79 @@
+//go:build linux
+
+package mexec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yankeguo/minit/internal/mlog"
+)
+
+// processAlive returns true if the process exists and is not a zombie
+func processAlive(pid int) bool {
+	buf, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(buf[strings.LastIndexByte(string(buf), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestManagerKillMode(t *testing.T) {
+	m := NewManager(ManagerOptions{})
+
+	logger, err := mlog.NewProcLogger(mlog.ProcLoggerOptions{})
+	require.NoError(t, err)
+
+	run := func(mode string, script string) (pid int, chErr chan error) {
+		file := filepath.Join(t.TempDir(), "pid")
+
+		chErr = make(chan error, 1)
+		go func() {
+			_, err := m.Execute(context.Background(), ExecuteOptions{
+				Name:     "daemon/" + mode,
+				Shell:    "/bin/bash",
+				Command:  []string{"sleep 100 &", "echo $! > " + file, script},
+				KillMode: mode,
+				Logger:   logger,
+			})
+			chErr <- err
+		}()
+
+		time.Sleep(time.Millisecond * 500)
+
+		buf, err := os.ReadFile(file)
+		require.NoError(t, err)
+		pid, err = strconv.Atoi(strings.TrimSpace(string(buf)))
+		require.NoError(t, err)
+		require.True(t, processAlive(pid))
+		return
+	}
+
+	// only the main process is stopped
+	pid, chErr := run(KillModeProcess, "wait")
+	<-m.Stop("daemon/"+KillModeProcess, syscall.SIGTERM)
+	<-chErr
+	require.True(t, processAlive(pid))
+	_ = syscall.Kill(pid, syscall.SIGKILL)
+
+	// the whole group is stopped
+	pid, chErr = run(KillModeGroup, "wait")
+	<-m.Stop("daemon/"+KillModeGroup, syscall.SIGTERM)
+	<-chErr
+	time.Sleep(time.Millisecond * 100)
+	require.False(t, processAlive(pid))
+
+	// remaining processes are killed once the main process exited
+	pid, chErr = run(KillModeMixed, "sleep 1")
+	require.NoError(t, <-chErr)
+	time.Sleep(time.Millisecond * 100)
+	require.False(t, processAlive(pid))
+}
@@ -2,8 +2,26 @@
 
 package mexec
 
-import "os/exec"
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
 
 func setSysProcAttr(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+}
+
+// signalGroup falls back to signal the process only, process groups are not created on this platform
+func signalGroup(pid int, sig os.Signal) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Signal(sig)
+}
 
+// processGroup returns pid itself, signalGroup only signals the process on this platform
+func processGroup(pid int) int {
+	return pid
 }
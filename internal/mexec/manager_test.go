@@ -1,8 +1,11 @@
 package mexec
 
 import (
+	"bytes"
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -12,7 +15,7 @@ import (
 )
 
 func TestNewManager(t *testing.T) {
-	m := NewManager()
+	m := NewManager(ManagerOptions{})
 
 	os.RemoveAll(filepath.Join("testdata", "test.out.log"))
 	os.RemoveAll(filepath.Join("testdata", "test.err.log"))
@@ -25,7 +28,7 @@ func TestNewManager(t *testing.T) {
 	})
 	require.NoError(t, err)
 
-	err = m.Execute(ExecuteOptions{
+	_, err = m.Execute(context.Background(), ExecuteOptions{
 		Dir: "testdata",
 		Env: map[string]string{
 			"AAA": "BBB",
@@ -48,7 +51,7 @@ func TestNewManager(t *testing.T) {
 
 	t1 := time.Now()
 
-	err = m.Execute(ExecuteOptions{
+	_, err = m.Execute(context.Background(), ExecuteOptions{
 		Dir: "testdata",
 		Env: map[string]string{
 			"AAA": "10",
@@ -62,3 +65,236 @@ func TestNewManager(t *testing.T) {
 	require.NoError(t, err)
 	require.True(t, time.Since(t1) < time.Second*2)
 }
+
+func TestManagerExecuteOutput(t *testing.T) {
+	m := NewManager(ManagerOptions{})
+
+	for i := 0; i < 20; i++ {
+		buf := &bytes.Buffer{}
+
+		logger, err := mlog.NewProcLogger(mlog.ProcLoggerOptions{
+			ConsoleOut: buf,
+			ConsoleErr: buf,
+		})
+		require.NoError(t, err)
+
+		_, err = m.Execute(context.Background(), ExecuteOptions{
+			Command: []string{"echo", "hello"},
+			Logger:  logger,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, strings.Count(buf.String(), "hello\n"))
+	}
+}
+
+func TestManagerSignalName(t *testing.T) {
+	m := NewManager(ManagerOptions{})
+
+	logger, err := mlog.NewProcLogger(mlog.ProcLoggerOptions{})
+	require.NoError(t, err)
+
+	chErr := make(chan error, 1)
+
+	go func() {
+		_, err := m.Execute(context.Background(), ExecuteOptions{
+			Name:    "daemon/b",
+			Command: []string{"sleep", "10"},
+			Logger:  logger,
+		})
+		chErr <- err
+	}()
+
+	t1 := time.Now()
+
+	_, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:    "daemon/a",
+		Command: []string{"sleep", "1"},
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	m.SignalName("daemon/a", syscall.SIGTERM)
+	m.SignalName("daemon/b", syscall.SIGTERM)
+
+	require.Error(t, <-chErr)
+	require.True(t, time.Since(t1) < time.Second*2)
+}
+
+func TestManagerStop(t *testing.T) {
+	m := NewManager(ManagerOptions{})
+
+	logger, err := mlog.NewProcLogger(mlog.ProcLoggerOptions{})
+	require.NoError(t, err)
+
+	chErr := make(chan error, 2)
+
+	go func() {
+		_, err := m.Execute(context.Background(), ExecuteOptions{
+			Name:        "daemon/stubborn",
+			Shell:       "/bin/bash",
+			Command:     []string{"trap '' TERM", "while true; do sleep 0.1; done"},
+			StopTimeout: time.Millisecond * 500,
+			Logger:      logger,
+		})
+		chErr <- err
+	}()
+
+	go func() {
+		_, err := m.Execute(context.Background(), ExecuteOptions{
+			Name:         "daemon/quit",
+			Shell:        "/bin/bash",
+			Command:      []string{"trap 'exit 3' QUIT", "while true; do sleep 0.1; done"},
+			StopSignal:   syscall.SIGQUIT,
+			SuccessCodes: []int{3},
+			Logger:       logger,
+		})
+		chErr <- err
+	}()
+
+	time.Sleep(time.Millisecond * 500)
+
+	require.Len(t, m.PIDs("daemon/quit"), 1)
+	require.Len(t, m.PIDs("daemon/unknown"), 0)
+
+	t1 := time.Now()
+
+	<-m.Stop("daemon/quit", syscall.SIGTERM)
+	require.NoError(t, <-chErr)
+	require.True(t, time.Since(t1) < time.Millisecond*400)
+
+	<-m.StopAll(syscall.SIGTERM)
+	require.Error(t, <-chErr)
+	require.True(t, time.Since(t1) >= time.Millisecond*500)
+	require.True(t, time.Since(t1) < time.Second*2)
+
+	// nothing to stop
+	<-m.StopAll(syscall.SIGTERM)
+}
+
+func TestManagerExecuteResult(t *testing.T) {
+	m := NewManager(ManagerOptions{})
+
+	logger, err := mlog.NewProcLogger(mlog.ProcLoggerOptions{})
+	require.NoError(t, err)
+
+	res, err := m.Execute(context.Background(), ExecuteOptions{
+		Name:         "once/exit",
+		Shell:        "/bin/bash",
+		Command:      []string{"exit 3"},
+		SuccessCodes: []int{3},
+		Logger:       logger,
+	})
+	require.NoError(t, err)
+	require.True(t, res.Started)
+	require.Equal(t, 3, res.ExitCode)
+	require.Equal(t, 3, res.ExitStatus())
+	require.True(t, res.Duration > 0)
+	require.True(t, res.MaxRSS > 0)
+
+	res, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:    "once/busy",
+		Shell:   "/bin/bash",
+		Command: []string{"i=0; while [ $i -lt 100000 ]; do i=$((i+1)); done"},
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+	require.True(t, res.UserTime > 0)
+
+	res, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:    "once/killed",
+		Shell:   "/bin/bash",
+		Command: []string{"kill -KILL $$"},
+		Logger:  logger,
+	})
+	require.Error(t, err)
+	require.Equal(t, syscall.SIGKILL, res.Signal)
+	require.Equal(t, 137, res.ExitStatus())
+
+	res, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:    "once/missing",
+		Command: []string{"/non-existing-command"},
+		Logger:  logger,
+	})
+	require.Error(t, err)
+	require.False(t, res.Started)
+}
+
+func TestManagerExecuteContext(t *testing.T) {
+	m := NewManager(ManagerOptions{})
+
+	logger, err := mlog.NewProcLogger(mlog.ProcLoggerOptions{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*300)
+	defer cancel()
+
+	t1 := time.Now()
+
+	res, err := m.Execute(ctx, ExecuteOptions{
+		Name:    "once/timeout",
+		Command: []string{"sleep", "10"},
+		Logger:  logger,
+	})
+	require.ErrorContains(t, err, "process stopped: context deadline exceeded")
+	require.True(t, res.Started)
+	require.Equal(t, syscall.SIGTERM, res.Signal)
+	require.True(t, time.Since(t1) < time.Second*2)
+
+	// killed after stop timeout
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(time.Millisecond * 300)
+		cancel()
+	}()
+
+	t1 = time.Now()
+
+	res, err = m.Execute(ctx, ExecuteOptions{
+		Name:        "cron/stubborn",
+		Shell:       "/bin/bash",
+		Command:     []string{"trap '' TERM", "while true; do sleep 0.1; done"},
+		StopTimeout: time.Millisecond * 300,
+		Logger:      logger,
+	})
+	require.ErrorContains(t, err, "process stopped: context canceled")
+	require.Equal(t, syscall.SIGKILL, res.Signal)
+	require.True(t, time.Since(t1) >= time.Millisecond*600)
+	require.True(t, time.Since(t1) < time.Second*2)
+
+	// not started with ctx done
+	res, err = m.Execute(ctx, ExecuteOptions{
+		Name:    "once/cancelled",
+		Command: []string{"true"},
+		Logger:  logger,
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.False(t, res.Started)
+}
+
+func TestExecuteResultString(t *testing.T) {
+	res := ExecuteResult{
+		Started:    true,
+		ExitCode:   3,
+		Duration:   time.Millisecond * 1234,
+		UserTime:   time.Millisecond * 800,
+		SystemTime: time.Microsecond * 100400,
+		MaxRSS:     12*1024*1024 + 512*1024,
+	}
+	require.Equal(t, "exit code 3, duration 1.234s, user 800ms, system 100ms, max rss 12.5MiB", res.String())
+
+	res = ExecuteResult{
+		Started:    true,
+		ExitCode:   -1,
+		Signal:     syscall.SIGSEGV,
+		CoreDumped: true,
+		MaxRSS:     100,
+	}
+	require.Equal(t, "signal SIGSEGV (core dumped), duration 0s, user 0s, system 0s, max rss 100B", res.String())
+}
+
+func TestFormatBytes(t *testing.T) {
+	require.Equal(t, "0B", formatBytes(0))
+	require.Equal(t, "1023B", formatBytes(1023))
+	require.Equal(t, "1.0KiB", formatBytes(1024))
+	require.Equal(t, "1.5GiB", formatBytes(1536*1024*1024))
+}
@@ -0,0 +1,11 @@
+package mexec
+
+import "time"
+
+// ProcStat is the state and resource usage of a running process
+type ProcStat struct {
+	State   string        // single character state, e.g. 'R', 'S' and 'Z' for zombie
+	PPID    int           // pid of parent process
+	CPUTime time.Duration // user and system cpu time
+	RSS     int64         // resident memory in bytes
+}
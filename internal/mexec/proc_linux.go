@@ -0,0 +1,59 @@
+//go:build linux
+
+package mexec
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is USER_HZ, it is 100 on all mainstream linux architectures
+const clockTicks = 100
+
+// ReadProcStat reads cpu time and resident memory of a process from /proc/<pid>/stat
+func ReadProcStat(pid int) (stat ProcStat, err error) {
+	var buf []byte
+	if buf, err = os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat"); err != nil {
+		return
+	}
+	return parseProcStat(string(buf))
+}
+
+func parseProcStat(content string) (stat ProcStat, err error) {
+	// process name is wrapped in parentheses and may contain spaces
+	idx := strings.LastIndexByte(content, ')')
+	if idx < 0 {
+		err = errors.New("invalid /proc/<pid>/stat content")
+		return
+	}
+
+	// fields starting from field 3 (state)
+	fields := strings.Fields(content[idx+1:])
+	if len(fields) < 22 {
+		err = errors.New("invalid /proc/<pid>/stat content")
+		return
+	}
+
+	stat.State = fields[0]
+	if stat.PPID, err = strconv.Atoi(fields[1]); err != nil {
+		return
+	}
+
+	var utime, stime, rss int64
+	if utime, err = strconv.ParseInt(fields[11], 10, 64); err != nil {
+		return
+	}
+	if stime, err = strconv.ParseInt(fields[12], 10, 64); err != nil {
+		return
+	}
+	if rss, err = strconv.ParseInt(fields[21], 10, 64); err != nil {
+		return
+	}
+
+	stat.CPUTime = time.Duration(utime+stime) * time.Second / clockTicks
+	stat.RSS = rss * int64(os.Getpagesize())
+	return
+}
@@ -0,0 +1,27 @@
+//go:build linux
+
+package mexec
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProcStat(t *testing.T) {
+	stat, err := parseProcStat("1234 (my (weird) proc) S 1 1234 1234 0 -1 4194560 1000 0 0 0 250 50 0 0 20 0 1 0 100 12345678 300 18446744073709551615")
+	require.NoError(t, err)
+	require.Equal(t, "S", stat.State)
+	require.Equal(t, 1, stat.PPID)
+	require.Equal(t, time.Second*3, stat.CPUTime)
+	require.Equal(t, int64(300*os.Getpagesize()), stat.RSS)
+
+	_, err = parseProcStat("1234 (broken")
+	require.Error(t, err)
+
+	stat, err = ReadProcStat(os.Getpid())
+	require.NoError(t, err)
+	require.True(t, stat.RSS > 0)
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package mexec
+
+import "errors"
+
+// ReadProcStat is only supported on linux
+func ReadProcStat(pid int) (stat ProcStat, err error) {
+	err = errors.New("reading process stat is not supported on this platform")
+	return
+}
@@ -0,0 +1,107 @@
+package mexec
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// processExit is the wait status of an exited process
+type processExit struct {
+	status syscall.WaitStatus
+	rusage syscall.Rusage
+}
+
+// err returns error like exec.ExitError does, nil if process exited with 0
+func (pe processExit) err() error {
+	switch {
+	case pe.status.Signaled():
+		return fmt.Errorf("signal: %s", pe.status.Signal())
+	case pe.status.ExitStatus() != 0:
+		return fmt.Errorf("exit status %d", pe.status.ExitStatus())
+	default:
+		return nil
+	}
+}
+
+// cpuTimes returns user and system CPU time of the process
+func (pe processExit) cpuTimes() (user time.Duration, system time.Duration) {
+	return time.Duration(pe.rusage.Utime.Nano()), time.Duration(pe.rusage.Stime.Nano())
+}
+
+// maxRSS returns maximum resident set size of the process in bytes
+func (pe processExit) maxRSS() int64 {
+	return int64(pe.rusage.Maxrss) * maxRSSUnit
+}
+
+// reaper collects exit statuses of all children once started, see StartReaper
+type reaper struct {
+	mu      sync.Mutex
+	started bool
+	waiters map[int]chan processExit
+}
+
+var theReaper = &reaper{waiters: map[int]chan processExit{}}
+
+// startCommand starts the command and returns a function waiting for it to exit. Once the reaper is started,
+// exec.Cmd.Wait must not be used, since the process may already be reaped, so stdin, stdout and stderr of cmd must
+// be nil or *os.File, to not rely on goroutines copying data until exec.Cmd.Wait.
+func startCommand(cmd *exec.Cmd) (wait func() (processExit, error), err error) {
+	// start and register in the same lock, so that the exit status can not be reaped before registration
+	theReaper.mu.Lock()
+	defer theReaper.mu.Unlock()
+
+	if err = cmd.Start(); err != nil {
+		return
+	}
+
+	if !theReaper.started {
+		wait = func() (pe processExit, err error) {
+			if err = cmd.Wait(); err != nil {
+				if _, ok := err.(*exec.ExitError); !ok {
+					return
+				}
+				err = nil
+			}
+			pe.status, _ = cmd.ProcessState.Sys().(syscall.WaitStatus)
+			if ru, ok := cmd.ProcessState.SysUsage().(*syscall.Rusage); ok {
+				pe.rusage = *ru
+			}
+			return
+		}
+		return
+	}
+
+	ch := make(chan processExit, 1)
+	theReaper.waiters[cmd.Process.Pid] = ch
+
+	wait = func() (pe processExit, err error) {
+		pe = <-ch
+		_ = cmd.Process.Release()
+		return
+	}
+	return
+}
+
+// RunCommand runs the command like exec.Cmd.Run, but works with the reaper, the process is killed once ctx is done.
+// Stdin, stdout and stderr of cmd must be nil or *os.File.
+func RunCommand(ctx context.Context, cmd *exec.Cmd) (err error) {
+	var wait func() (processExit, error)
+	if wait, err = startCommand(cmd); err != nil {
+		return
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		_ = cmd.Process.Kill()
+	})
+	defer stop()
+
+	var pe processExit
+	if pe, err = wait(); err != nil {
+		return
+	}
+	return pe.err()
+}
@@ -0,0 +1,163 @@
+//go:build linux
+
+package mexec
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// maxRSSUnit is the unit of rusage.Maxrss, kilobytes on linux
+	maxRSSUnit = 1024
+
+	// watchPollInterval is the interval of checking existence of a watched process which is not a child of minit
+	watchPollInterval = time.Second
+)
+
+// SetSubreaper marks minit as a child subreaper, orphaned descendants are re-parented to minit instead of PID 1,
+// used when minit is not running as PID 1
+func SetSubreaper() error {
+	return unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0)
+}
+
+// StartReaper starts the central reaper, all exited children are reaped with wait4(-1, WNOHANG) on SIGCHLD, exit
+// statuses of processes started by this package are handed back to them, others, e.g. orphaned descendants adopted
+// by PID 1, are reaped silently. Once started, child processes must be started with Manager or RunCommand.
+func StartReaper() {
+	theReaper.mu.Lock()
+	defer theReaper.mu.Unlock()
+
+	if theReaper.started {
+		return
+	}
+	theReaper.started = true
+
+	chSig := make(chan os.Signal, 1)
+	signal.Notify(chSig, syscall.SIGCHLD)
+
+	go func() {
+		for range chSig {
+			theReaper.reapOnce()
+		}
+	}()
+
+	// children exited before signal.Notify
+	go theReaper.reapOnce()
+}
+
+// reapOnce reaps all exited children without blocking, exit statuses are handed to registered waiters,
+// others are dropped
+func (r *reaper) reapOnce() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for {
+		var pe processExit
+		pid, err := syscall.Wait4(-1, &pe.status, syscall.WNOHANG, &pe.rusage)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || pid <= 0 {
+			return
+		}
+		if ch, ok := r.waiters[pid]; ok {
+			delete(r.waiters, pid)
+			ch <- pe
+		}
+	}
+}
+
+// watchProcess returns a function waiting for a running process not started by this package to exit, e.g. a daemon
+// forked by a launcher. Exit status is handed back if the process is a child of minit with the reaper started, like
+// an orphan adopted by minit, otherwise ok of wait is false and the process is polled until disappeared.
+func watchProcess(pid int) (wait func() (pe processExit, ok bool), err error) {
+	theReaper.mu.Lock()
+	defer theReaper.mu.Unlock()
+
+	var stat ProcStat
+	if stat, err = ReadProcStat(pid); err != nil {
+		return
+	}
+
+	// zombies not reaped yet are waited as well, reapOnce runs with the lock held
+	if theReaper.started && stat.PPID == os.Getpid() {
+		ch := make(chan processExit, 1)
+		theReaper.waiters[pid] = ch
+
+		wait = func() (processExit, bool) {
+			return <-ch, true
+		}
+		return
+	}
+
+	if stat.State == "Z" {
+		err = errors.New("process " + strconv.Itoa(pid) + " is a zombie")
+		return
+	}
+
+	wait = func() (pe processExit, ok bool) {
+		for {
+			time.Sleep(watchPollInterval)
+			if stat, err := ReadProcStat(pid); err != nil || stat.State == "Z" {
+				return
+			}
+		}
+	}
+	return
+}
+
+// AdoptedPIDs returns pids of alive children not started or watched by this package, i.e. orphaned descendants adopted
+// by minit, always empty if the reaper is not started
+func AdoptedPIDs() (pids []int) {
+	theReaper.mu.Lock()
+	defer theReaper.mu.Unlock()
+
+	if !theReaper.started {
+		return
+	}
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return
+	}
+
+	self := os.Getpid()
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if _, ok := theReaper.waiters[pid]; ok {
+			continue
+		}
+		stat, err := ReadProcStat(pid)
+		if err != nil || stat.PPID != self || stat.State == "Z" {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+	return
+}
+
+// SignalAdopted sends signal to all processes returned by AdoptedPIDs, and returns their pids
+func SignalAdopted(sig os.Signal) (pids []int) {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return
+	}
+	pids = AdoptedPIDs()
+	for _, pid := range pids {
+		_ = syscall.Kill(pid, s)
+	}
+	return
+}
@@ -0,0 +1,188 @@
+//go:build linux
+
+package mexec
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yankeguo/minit/internal/mlog"
+)
+
+func TestReaper(t *testing.T) {
+	StartReaper()
+
+	m := NewManager(ManagerOptions{})
+
+	logger, err := mlog.NewProcLogger(mlog.ProcLoggerOptions{})
+	require.NoError(t, err)
+
+	// exit statuses of managed processes are handed back, even when executed concurrently
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := m.Execute(context.Background(), ExecuteOptions{
+				Name:         "once/reaper-" + strconv.Itoa(i),
+				Shell:        "/bin/sh",
+				Command:      []string{"exit " + strconv.Itoa(i%5)},
+				SuccessCodes: []int{0, 1, 2, 3, 4},
+				Logger:       logger,
+			})
+			require.NoError(t, err)
+			require.True(t, res.Started)
+			require.Equal(t, i%5, res.ExitCode)
+		}(i)
+	}
+	wg.Wait()
+
+	// unmanaged children are reaped silently
+	cmd := exec.Command("true")
+	require.NoError(t, cmd.Start())
+	pid := cmd.Process.Pid
+	require.Eventually(t, func() bool {
+		_, err := os.Stat("/proc/" + strconv.Itoa(pid))
+		return os.IsNotExist(err)
+	}, time.Second*3, time.Millisecond*50)
+
+	// RunCommand
+	require.NoError(t, RunCommand(context.Background(), exec.Command("true")))
+	require.EqualError(t, RunCommand(context.Background(), exec.Command("sh", "-c", "exit 3")), "exit status 3")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	require.EqualError(t, RunCommand(ctx, exec.Command("sleep", "10")), "signal: killed")
+}
+
+func TestReaperAdopted(t *testing.T) {
+	require.NoError(t, SetSubreaper())
+	StartReaper()
+
+	m := NewManager(ManagerOptions{})
+
+	logger, err := mlog.NewProcLogger(mlog.ProcLoggerOptions{})
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "pid")
+
+	// background job is orphaned once shell exited, and re-parented to us
+	_, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:    "once/orphan",
+		Shell:   "/bin/sh",
+		Command: []string{"sleep 100 > /dev/null 2>&1 &", "echo $! > " + file},
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	buf, err := os.ReadFile(file)
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(buf)))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return slices.Contains(AdoptedPIDs(), pid)
+	}, time.Second*3, time.Millisecond*50)
+
+	require.Contains(t, SignalAdopted(syscall.SIGTERM), pid)
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat("/proc/" + strconv.Itoa(pid))
+		return len(AdoptedPIDs()) == 0 && os.IsNotExist(err)
+	}, time.Second*3, time.Millisecond*50)
+}
+
+func TestManagerWatch(t *testing.T) {
+	require.NoError(t, SetSubreaper())
+	StartReaper()
+
+	m := NewManager(ManagerOptions{})
+
+	logger, err := mlog.NewProcLogger(mlog.ProcLoggerOptions{})
+	require.NoError(t, err)
+
+	// forks a daemon like legacy launchers do, returns pid of the daemon
+	fork := func(script string) int {
+		file := filepath.Join(t.TempDir(), "pid")
+
+		_, err := m.Execute(context.Background(), ExecuteOptions{
+			Name:    "daemon/launcher",
+			Shell:   "/bin/sh",
+			Command: []string{"sh -c '" + script + "' > /dev/null 2>&1 &", "echo $! > " + file},
+			Logger:  logger,
+		})
+		require.NoError(t, err)
+
+		buf, err := os.ReadFile(file)
+		require.NoError(t, err)
+		pid, err := strconv.Atoi(strings.TrimSpace(string(buf)))
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			return slices.Contains(AdoptedPIDs(), pid)
+		}, time.Second*3, time.Millisecond*50)
+		return pid
+	}
+
+	// exit status of adopted daemon is known
+	pid := fork("sleep 0.3; exit 3")
+
+	res, err := m.Watch(context.Background(), ExecuteOptions{
+		Name:   "daemon/forking",
+		Logger: logger,
+	}, pid)
+	require.EqualError(t, err, "exit code: 3 is not in success_codes")
+	require.True(t, res.Started)
+	require.Equal(t, 3, res.ExitCode)
+
+	// watched daemon is managed, and stopped like started ones
+	pid = fork("sleep 100")
+
+	chErr := make(chan error, 1)
+	go func() {
+		_, err := m.Watch(context.Background(), ExecuteOptions{
+			Name:   "daemon/forking",
+			Logger: logger,
+		}, pid)
+		chErr <- err
+	}()
+
+	require.Eventually(t, func() bool {
+		return slices.Equal(m.PIDs("daemon/forking"), []int{pid})
+	}, time.Second*3, time.Millisecond*50)
+	require.NotContains(t, AdoptedPIDs(), pid)
+
+	<-m.Stop("daemon/forking", syscall.SIGTERM)
+	require.EqualError(t, <-chErr, "exit code: -1 is not in success_codes")
+	require.Empty(t, m.PIDs("daemon/forking"))
+
+	// stopped by ctx
+	pid = fork("sleep 100")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*300)
+	defer cancel()
+
+	res, err = m.Watch(ctx, ExecuteOptions{
+		Name:   "daemon/forking",
+		Logger: logger,
+	}, pid)
+	require.EqualError(t, err, "process stopped: context deadline exceeded")
+	require.Equal(t, syscall.SIGTERM, res.Signal)
+
+	// not running
+	_, err = m.Watch(context.Background(), ExecuteOptions{
+		Name:   "daemon/forking",
+		Logger: logger,
+	}, pid)
+	require.Error(t, err)
+}
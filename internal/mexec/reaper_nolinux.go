@@ -0,0 +1,36 @@
+//go:build !linux
+
+package mexec
+
+import (
+	"errors"
+	"os"
+)
+
+// maxRSSUnit is the unit of rusage.Maxrss, bytes on darwin
+const maxRSSUnit = 1
+
+// StartReaper is only supported on linux, children are waited by exec.Cmd.Wait on other platforms
+func StartReaper() {
+}
+
+// SetSubreaper is only supported on linux
+func SetSubreaper() error {
+	return errors.New("child subreaper is not supported on this platform")
+}
+
+// AdoptedPIDs is always empty on this platform
+func AdoptedPIDs() (pids []int) {
+	return
+}
+
+// watchProcess is only supported on linux
+func watchProcess(pid int) (wait func() (pe processExit, ok bool), err error) {
+	err = errors.New("watching process is not supported on this platform")
+	return
+}
+
+// SignalAdopted does nothing on this platform
+func SignalAdopted(sig os.Signal) (pids []int) {
+	return
+}
@@ -0,0 +1,131 @@
+//go:build linux
+
+package mexec
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const Unlimited = "unlimited"
+
+var (
+	knownRLimitNames = map[string]int{
+		"AS":         unix.RLIMIT_AS,
+		"CORE":       unix.RLIMIT_CORE,
+		"CPU":        unix.RLIMIT_CPU,
+		"DATA":       unix.RLIMIT_DATA,
+		"FSIZE":      unix.RLIMIT_FSIZE,
+		"LOCKS":      unix.RLIMIT_LOCKS,
+		"MEMLOCK":    unix.RLIMIT_MEMLOCK,
+		"MSGQUEUE":   unix.RLIMIT_MSGQUEUE,
+		"NICE":       unix.RLIMIT_NICE,
+		"NOFILE":     unix.RLIMIT_NOFILE,
+		"NPROC":      unix.RLIMIT_NPROC,
+		"RTPRIO":     unix.RLIMIT_RTPRIO,
+		"SIGPENDING": unix.RLIMIT_SIGPENDING,
+		"STACK":      unix.RLIMIT_STACK,
+	}
+)
+
+// RLimitNames returns upper-cased names of supported resources, e.g. NOFILE
+func RLimitNames() (names []string) {
+	for name := range knownRLimitNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// RLimitResource returns the resource of the case-insensitive name, e.g. 'nofile'
+func RLimitResource(name string) (res int, ok bool) {
+	res, ok = knownRLimitNames[strings.ToUpper(name)]
+	return
+}
+
+// DecodeRLimitValue decodes a number or 'unlimited' into v, '-' or empty string keeps v unchanged
+func DecodeRLimitValue(v *uint64, s string) (err error) {
+	s = strings.TrimSpace(s)
+	if s == "-" || s == "" {
+		return
+	}
+	if strings.ToLower(s) == Unlimited {
+		*v = unix.RLIM_INFINITY
+	} else {
+		if *v, err = strconv.ParseUint(s, 10, 64); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func FormatRLimitValue(v uint64) string {
+	if v == unix.RLIM_INFINITY {
+		return Unlimited
+	} else {
+		return strconv.FormatUint(v, 10)
+	}
+}
+
+// DecodeRLimit decodes 'soft:hard', or a single value for both, into limit like MINIT_RLIMIT_XXX, a single '-' keeps
+// both unchanged, see DecodeRLimitValue
+func DecodeRLimit(limit *unix.Rlimit, s string) (err error) {
+	if strings.Contains(s, ":") {
+		splits := strings.Split(s, ":")
+		if len(splits) != 2 {
+			err = errors.New("must be in format 'soft:hard'")
+			return
+		}
+		if err = DecodeRLimitValue(&limit.Cur, splits[0]); err != nil {
+			return
+		}
+		if err = DecodeRLimitValue(&limit.Max, splits[1]); err != nil {
+			return
+		}
+	} else if s = strings.TrimSpace(s); s != "-" && s != "" {
+		if err = DecodeRLimitValue(&limit.Cur, s); err != nil {
+			return
+		}
+		limit.Max = limit.Cur
+	}
+	return
+}
+
+// ValidateRLimits validates names and values of rlimits
+func ValidateRLimits(rlimits map[string]string) error {
+	for name, val := range rlimits {
+		if _, ok := RLimitResource(name); !ok {
+			return errors.New("unknown rlimit '" + name + "', must be one of: " + strings.ToLower(strings.Join(RLimitNames(), ", ")))
+		}
+		var limit unix.Rlimit
+		if err := DecodeRLimit(&limit, val); err != nil {
+			return errors.New("invalid rlimit " + name + "=" + val + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// setRLimits applies rlimits to a started process with prlimit, values like '-' are kept from current limits
+func setRLimits(pid int, rlimits map[string]string) error {
+	for name, val := range rlimits {
+		res, ok := RLimitResource(name)
+		if !ok {
+			return errors.New("unknown rlimit '" + name + "'")
+		}
+		var limit unix.Rlimit
+		if err := unix.Prlimit(pid, res, nil, &limit); err != nil {
+			return errors.New("failed getting rlimit " + name + ": " + err.Error())
+		}
+		if err := DecodeRLimit(&limit, val); err != nil {
+			return errors.New("invalid rlimit " + name + "=" + val + ": " + err.Error())
+		}
+		if err := unix.Prlimit(pid, res, &limit, nil); err != nil {
+			return errors.New("failed setting rlimit " + name + "=" + val + ": " + err.Error())
+		}
+	}
+	return nil
+}
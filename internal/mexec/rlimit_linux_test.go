@@ -0,0 +1,84 @@
+//go:build linux
+
+package mexec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yankeguo/minit/internal/mlog"
+	"golang.org/x/sys/unix"
+)
+
+func TestDecodeRLimit(t *testing.T) {
+	limit := unix.Rlimit{Cur: 1, Max: 2}
+	require.NoError(t, DecodeRLimit(&limit, "-:unlimited"))
+	require.Equal(t, unix.Rlimit{Cur: 1, Max: unix.RLIM_INFINITY}, limit)
+
+	require.NoError(t, DecodeRLimit(&limit, "-"))
+	require.Equal(t, unix.Rlimit{Cur: 1, Max: unix.RLIM_INFINITY}, limit)
+
+	require.NoError(t, DecodeRLimit(&limit, "128:-"))
+	require.Equal(t, unix.Rlimit{Cur: 128, Max: unix.RLIM_INFINITY}, limit)
+
+	require.NoError(t, DecodeRLimit(&limit, "1024"))
+	require.Equal(t, unix.Rlimit{Cur: 1024, Max: 1024}, limit)
+
+	require.Error(t, DecodeRLimit(&limit, "1:2:3"))
+	require.Error(t, DecodeRLimit(&limit, "many"))
+
+	require.NoError(t, ValidateRLimits(map[string]string{"nofile": "65536:65536", "CORE": "unlimited"}))
+	require.Error(t, ValidateRLimits(map[string]string{"files": "1"}))
+	require.Error(t, ValidateRLimits(map[string]string{"nofile": "1:x"}))
+}
+
+func TestManagerExecuteRLimits(t *testing.T) {
+	m := NewManager(ManagerOptions{})
+
+	dir := t.TempDir()
+
+	logger, err := mlog.NewProcLogger(mlog.ProcLoggerOptions{
+		FileOptions: &mlog.RotatingFileOptions{
+			Dir:      dir,
+			Filename: "rlimits",
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:    "once/rlimits",
+		Shell:   "/bin/sh",
+		Command: []string{"sleep 0.2", `echo "nofile=$(ulimit -n):$(ulimit -Hn)"`},
+		RLimits: map[string]string{"nofile": "512:1024"},
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+
+	buf, err := os.ReadFile(filepath.Join(dir, "rlimits.out.log"))
+	require.NoError(t, err)
+	require.Contains(t, string(buf), "nofile=512:1024")
+
+	// hard limits are kept unless given
+	var limit unix.Rlimit
+	require.NoError(t, unix.Getrlimit(unix.RLIMIT_NOFILE, &limit))
+	hard := FormatRLimitValue(limit.Max)
+
+	for _, val := range []string{"-", "256:-"} {
+		_, err = m.Execute(context.Background(), ExecuteOptions{
+			Name:    "once/rlimits",
+			Shell:   "/bin/sh",
+			Command: []string{"sleep 0.2", `echo "nofile=$(ulimit -Hn)"`},
+			RLimits: map[string]string{"nofile": val},
+			Logger:  logger,
+		})
+		require.NoError(t, err)
+	}
+
+	buf, err = os.ReadFile(filepath.Join(dir, "rlimits.out.log"))
+	require.NoError(t, err)
+	require.Equal(t, 2, strings.Count(string(buf), "nofile="+hard+"\n"))
+}
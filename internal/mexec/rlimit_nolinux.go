@@ -0,0 +1,17 @@
+//go:build !linux
+
+package mexec
+
+import "errors"
+
+// ValidateRLimits is only supported on linux
+func ValidateRLimits(rlimits map[string]string) error {
+	if len(rlimits) == 0 {
+		return nil
+	}
+	return errors.New("rlimits are not supported on this platform")
+}
+
+func setRLimits(pid int, rlimits map[string]string) error {
+	return ValidateRLimits(rlimits)
+}
@@ -0,0 +1,76 @@
+package mexec
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	IONiceClassRealtime   = "realtime"
+	IONiceClassBestEffort = "best-effort"
+	IONiceClassIdle       = "idle"
+
+	DefaultIONiceLevel = 4
+)
+
+// SchedOptions are scheduling options of a process, applied right after started, zero values are not set
+type SchedOptions struct {
+	Nice        int    // -20 (highest priority) to 19 (lowest priority)
+	IONiceClass string // one of IONiceClassRealtime, IONiceClassBestEffort and IONiceClassIdle
+	IONiceLevel *int   // 0 (highest priority) to 7 (lowest priority), default is DefaultIONiceLevel
+	CPUAffinity string // cpu list, e.g. '0-3,6'
+	OOMScoreAdj *int   // -1000 to 1000, default is the value before minit changed its own, see SetOOMScoreAdj
+}
+
+// Validate checks ranges and formats of values
+func (o SchedOptions) Validate() error {
+	if o.Nice < -20 || o.Nice > 19 {
+		return errors.New("invalid nice " + strconv.Itoa(o.Nice) + ", must be within -20 to 19")
+	}
+	switch o.IONiceClass {
+	case "", IONiceClassRealtime, IONiceClassBestEffort, IONiceClassIdle:
+	default:
+		return errors.New("invalid ionice class '" + o.IONiceClass + "', must be one of: realtime, best-effort, idle")
+	}
+	if o.IONiceLevel != nil {
+		if o.IONiceClass == "" {
+			return errors.New("ionice level requires ionice class")
+		}
+		if *o.IONiceLevel < 0 || *o.IONiceLevel > 7 {
+			return errors.New("invalid ionice level " + strconv.Itoa(*o.IONiceLevel) + ", must be within 0 to 7")
+		}
+	}
+	if _, err := ParseCPUList(o.CPUAffinity); err != nil {
+		return errors.New("invalid cpu affinity '" + o.CPUAffinity + "': " + err.Error())
+	}
+	if o.OOMScoreAdj != nil && (*o.OOMScoreAdj < -1000 || *o.OOMScoreAdj > 1000) {
+		return errors.New("invalid oom score adj " + strconv.Itoa(*o.OOMScoreAdj) + ", must be within -1000 to 1000")
+	}
+	return nil
+}
+
+// ParseCPUList parses a cpu list like '0-3,6', separated by commas or spaces
+func ParseCPUList(s string) (cpus []int, err error) {
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		first, last, isRange := strings.Cut(item, "-")
+
+		var from, to int
+		if from, err = strconv.Atoi(first); err != nil || from < 0 {
+			err = errors.New("invalid cpu '" + item + "'")
+			return
+		}
+		to = from
+		if isRange {
+			if to, err = strconv.Atoi(last); err != nil || to < from {
+				err = errors.New("invalid cpu range '" + item + "'")
+				return
+			}
+		}
+
+		for cpu := from; cpu <= to; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return
+}
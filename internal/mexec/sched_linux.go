@@ -0,0 +1,113 @@
+//go:build linux
+
+package mexec
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+)
+
+var (
+	ioniceClasses = map[string]int{
+		IONiceClassRealtime:   1,
+		IONiceClassBestEffort: 2,
+		IONiceClassIdle:       3,
+	}
+
+	// oomScoreAdjDefault is the oom_score_adj of minit before SetOOMScoreAdj, children inherit the lowered value
+	// otherwise
+	oomScoreAdjDefault     *int
+	oomScoreAdjDefaultLock sync.Mutex
+)
+
+// SetOOMScoreAdj sets oom_score_adj of minit itself, processes started later are reset to the previous value
+func SetOOMScoreAdj(v int) (err error) {
+	oomScoreAdjDefaultLock.Lock()
+	defer oomScoreAdjDefaultLock.Unlock()
+
+	var buf []byte
+	if buf, err = os.ReadFile("/proc/self/oom_score_adj"); err != nil {
+		return
+	}
+	var prev int
+	if prev, err = strconv.Atoi(strings.TrimSpace(string(buf))); err != nil {
+		return
+	}
+
+	if err = writeOOMScoreAdj("self", v); err != nil {
+		return
+	}
+
+	if oomScoreAdjDefault == nil {
+		oomScoreAdjDefault = &prev
+	}
+	return
+}
+
+func writeOOMScoreAdj(pid string, v int) error {
+	return os.WriteFile("/proc/"+pid+"/oom_score_adj", []byte(strconv.Itoa(v)), 0644)
+}
+
+// setSched applies scheduling options to a started process
+func setSched(pid int, opts SchedOptions) (err error) {
+	if opts.Nice != 0 {
+		if err = unix.Setpriority(unix.PRIO_PROCESS, pid, opts.Nice); err != nil {
+			return errors.New("failed setting nice: " + err.Error())
+		}
+	}
+
+	if opts.IONiceClass != "" {
+		class, ok := ioniceClasses[opts.IONiceClass]
+		if !ok {
+			return errors.New("unknown ionice class: " + opts.IONiceClass)
+		}
+		level := DefaultIONiceLevel
+		if opts.IONiceLevel != nil {
+			level = *opts.IONiceLevel
+		}
+		if opts.IONiceClass == IONiceClassIdle {
+			level = 0
+		}
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(class<<ioprioClassShift|level)); errno != 0 {
+			return errors.New("failed setting ionice: " + errno.Error())
+		}
+	}
+
+	if opts.CPUAffinity != "" {
+		var cpus []int
+		if cpus, err = ParseCPUList(opts.CPUAffinity); err != nil {
+			return
+		}
+		var set unix.CPUSet
+		for _, cpu := range cpus {
+			set.Set(cpu)
+		}
+		if err = unix.SchedSetaffinity(pid, &set); err != nil {
+			return errors.New("failed setting cpu affinity: " + err.Error())
+		}
+	}
+
+	oomScoreAdj := opts.OOMScoreAdj
+	if oomScoreAdj == nil {
+		oomScoreAdjDefaultLock.Lock()
+		oomScoreAdj = oomScoreAdjDefault
+		oomScoreAdjDefaultLock.Unlock()
+	}
+	if oomScoreAdj != nil {
+		if err = writeOOMScoreAdj(strconv.Itoa(pid), *oomScoreAdj); err != nil {
+			return errors.New("failed setting oom_score_adj: " + err.Error())
+		}
+	}
+
+	return
+}
@@ -0,0 +1,84 @@
+//go:build linux
+
+package mexec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yankeguo/minit/internal/mlog"
+)
+
+func TestManagerExecuteSched(t *testing.T) {
+	m := NewManager(ManagerOptions{})
+
+	dir := t.TempDir()
+
+	logger, err := mlog.NewProcLogger(mlog.ProcLoggerOptions{
+		FileOptions: &mlog.RotatingFileOptions{
+			Dir:      dir,
+			Filename: "sched",
+		},
+	})
+	require.NoError(t, err)
+
+	read := func() string {
+		buf, err := os.ReadFile(filepath.Join(dir, "sched.out.log"))
+		require.NoError(t, err)
+		return string(buf)
+	}
+
+	script := []string{
+		"sleep 0.2",
+		`echo "nice=$(nice)"`,
+		`echo "oom=$(cat /proc/$$/oom_score_adj)"`,
+		`grep Cpus_allowed_list /proc/$$/status`,
+	}
+
+	level, oom := 6, 500
+
+	_, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:    "daemon/sched",
+		Shell:   "/bin/sh",
+		Command: append(script, `ionice -p $$`),
+		Sched: SchedOptions{
+			Nice:        5,
+			IONiceClass: IONiceClassBestEffort,
+			IONiceLevel: &level,
+			CPUAffinity: "0",
+			OOMScoreAdj: &oom,
+		},
+		Logger: logger,
+	})
+	require.NoError(t, err)
+
+	out := read()
+	require.Contains(t, out, "nice=5")
+	require.Contains(t, out, "oom=500")
+	require.Regexp(t, `Cpus_allowed_list:\s+0\n`, out)
+	require.Contains(t, out, "best-effort: prio 6")
+
+	// processes are reset to the oom_score_adj before minit changed its own, raising works without privileges
+	buf, err := os.ReadFile("/proc/self/oom_score_adj")
+	require.NoError(t, err)
+	prev := strings.TrimSpace(string(buf))
+
+	require.NoError(t, SetOOMScoreAdj(100))
+	defer func() {
+		_ = os.WriteFile("/proc/self/oom_score_adj", buf, 0644)
+		oomScoreAdjDefault = nil
+	}()
+
+	_, err = m.Execute(context.Background(), ExecuteOptions{
+		Name:    "daemon/sched",
+		Shell:   "/bin/sh",
+		Command: script,
+		Logger:  logger,
+	})
+	require.NoError(t, err)
+	require.Contains(t, read(), "nice=0\noom="+prev+"\n")
+}
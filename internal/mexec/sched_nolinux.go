@@ -0,0 +1,17 @@
+//go:build !linux
+
+package mexec
+
+import "errors"
+
+// SetOOMScoreAdj is only supported on linux
+func SetOOMScoreAdj(v int) error {
+	return errors.New("oom_score_adj is not supported on this platform")
+}
+
+func setSched(pid int, opts SchedOptions) error {
+	if opts.Nice == 0 && opts.IONiceClass == "" && opts.CPUAffinity == "" && opts.OOMScoreAdj == nil {
+		return nil
+	}
+	return errors.New("scheduling options are not supported on this platform")
+}
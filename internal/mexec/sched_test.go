@@ -0,0 +1,35 @@
+package mexec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCPUList(t *testing.T) {
+	cpus, err := ParseCPUList("0-3,6 8")
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 2, 3, 6, 8}, cpus)
+
+	cpus, err = ParseCPUList("")
+	require.NoError(t, err)
+	require.Empty(t, cpus)
+
+	_, err = ParseCPUList("3-1")
+	require.Error(t, err)
+	_, err = ParseCPUList("a")
+	require.Error(t, err)
+}
+
+func TestSchedOptionsValidate(t *testing.T) {
+	level, badLevel, oom := 7, 8, -1001
+
+	require.NoError(t, SchedOptions{}.Validate())
+	require.NoError(t, SchedOptions{Nice: -20, IONiceClass: IONiceClassBestEffort, IONiceLevel: &level, CPUAffinity: "0-1"}.Validate())
+	require.Error(t, SchedOptions{Nice: 20}.Validate())
+	require.Error(t, SchedOptions{IONiceClass: "fast"}.Validate())
+	require.Error(t, SchedOptions{IONiceLevel: &level}.Validate())
+	require.Error(t, SchedOptions{IONiceClass: IONiceClassRealtime, IONiceLevel: &badLevel}.Validate())
+	require.Error(t, SchedOptions{CPUAffinity: "0-"}.Validate())
+	require.Error(t, SchedOptions{OOMScoreAdj: &oom}.Validate())
+}
@@ -0,0 +1,52 @@
+package mexec
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	// ForwardableSignals are non-terminating signals minit can forward to managed processes
+	ForwardableSignals = []syscall.Signal{
+		syscall.SIGHUP,
+		syscall.SIGUSR1,
+		syscall.SIGUSR2,
+		syscall.SIGWINCH,
+		syscall.SIGQUIT,
+	}
+)
+
+// ParseSignal parses a signal from name like 'SIGQUIT', 'QUIT' or number like '3'
+func ParseSignal(s string) (sig syscall.Signal, err error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+
+	if n, errNum := strconv.Atoi(s); errNum == nil {
+		if n <= 0 || n >= 65 {
+			err = errors.New("invalid signal number: " + s)
+			return
+		}
+		sig = syscall.Signal(n)
+		return
+	}
+
+	if !strings.HasPrefix(s, "SIG") {
+		s = "SIG" + s
+	}
+
+	if sig = unix.SignalNum(s); sig == 0 {
+		err = errors.New("unknown signal: " + s)
+	}
+	return
+}
+
+// signalName returns name of the signal like 'SIGQUIT', or number if unknown
+func signalName(sig syscall.Signal) string {
+	if name := unix.SignalName(sig); name != "" {
+		return name
+	}
+	return strconv.Itoa(int(sig))
+}
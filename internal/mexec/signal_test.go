@@ -0,0 +1,23 @@
+package mexec
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSignal(t *testing.T) {
+	for _, s := range []string{"SIGQUIT", "quit", " Quit ", "3"} {
+		sig, err := ParseSignal(s)
+		require.NoError(t, err)
+		require.Equal(t, syscall.SIGQUIT, sig)
+	}
+
+	_, err := ParseSignal("SIGWHAT")
+	require.Error(t, err)
+	_, err = ParseSignal("0")
+	require.Error(t, err)
+	_, err = ParseSignal("")
+	require.Error(t, err)
+}
@@ -0,0 +1,113 @@
+package mexec
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// SuExecName is the name minit works as 'su-exec' with, if executed as, e.g. by symlink
+	SuExecName = "su-exec"
+
+	suExecUsage = `usage:
+  minit exec --user <user> [--group <group>] [--groups <group,...>] -- <command> [arguments]
+  minit su-exec <user>[:<group>] <command> [arguments]
+  su-exec <user>[:<group>] <command> [arguments]
+
+user and group can be names or numeric IDs, supplementary groups default to groups of the user,
+or only the group if it is given or the user is unknown`
+)
+
+// IsSuExec returns true if minit is executed as 'su-exec'
+func IsSuExec(args []string) bool {
+	return len(args) > 0 && filepath.Base(args[0]) == SuExecName
+}
+
+// RunSuExec switches user and executes the command in place, with arguments like 'su-exec', it only returns on failure
+func RunSuExec(args []string) (err error) {
+	if len(args) < 2 || args[0] == "" {
+		return errors.New(suExecUsage)
+	}
+	return suExec(args[0], nil, args[1:])
+}
+
+// RunExec is like RunSuExec, but with flags, arguments after '--' are the command
+func RunExec(args []string) (err error) {
+	var (
+		spec   string
+		groups []string
+		argv   []string
+	)
+	if spec, groups, argv, err = parseExecArgs(args); err != nil {
+		return
+	}
+	return suExec(spec, groups, argv)
+}
+
+// parseExecArgs parses arguments of RunExec to a 'user[:group]' spec, supplementary groups and the command
+func parseExecArgs(args []string) (spec string, groups []string, argv []string, err error) {
+	fs := flag.NewFlagSet("exec", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	var (
+		optUser   string
+		optGroup  string
+		optGroups string
+	)
+	fs.StringVar(&optUser, "user", "", "user to run the command as")
+	fs.StringVar(&optGroup, "group", "", "group to run the command as, default to group of the user")
+	fs.StringVar(&optGroups, "groups", "", "supplementary groups, comma separated")
+
+	if err = fs.Parse(args); err != nil {
+		err = errors.New(err.Error() + "\n" + suExecUsage)
+		return
+	}
+
+	if optUser == "" || fs.NArg() == 0 {
+		err = errors.New(suExecUsage)
+		return
+	}
+
+	spec = optUser
+	if optGroup != "" {
+		spec += ":" + optGroup
+	}
+
+	if optGroups != "" {
+		groups = []string{}
+		for _, item := range strings.Split(optGroups, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				groups = append(groups, item)
+			}
+		}
+	}
+
+	argv = fs.Args()
+	return
+}
+
+// suExec resolves the credential, sets HOME and USER, and executes the command in place
+func suExec(spec string, groups []string, argv []string) (err error) {
+	var cred Credential
+	if cred, err = LookupCredential(spec, groups); err != nil {
+		return
+	}
+
+	if err = os.Setenv("HOME", cred.Home); err != nil {
+		return
+	}
+	if cred.Username != "" {
+		err = os.Setenv("USER", cred.Username)
+	} else {
+		err = os.Unsetenv("USER")
+	}
+	if err != nil {
+		return
+	}
+
+	return Exec(HelperOptions{Credential: &cred}, argv)
+}
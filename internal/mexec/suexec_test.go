@@ -0,0 +1,39 @@
+package mexec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsSuExec(t *testing.T) {
+	require.True(t, IsSuExec([]string{"su-exec", "app", "id"}))
+	require.True(t, IsSuExec([]string{"/usr/local/bin/su-exec"}))
+	require.False(t, IsSuExec([]string{"/minit", "su-exec"}))
+	require.False(t, IsSuExec(nil))
+}
+
+func TestParseExecArgs(t *testing.T) {
+	spec, groups, argv, err := parseExecArgs([]string{"--user", "app", "--group", "staff", "--", "id", "-u"})
+	require.NoError(t, err)
+	require.Equal(t, "app:staff", spec)
+	require.Nil(t, groups)
+	require.Equal(t, []string{"id", "-u"}, argv)
+
+	spec, groups, argv, err = parseExecArgs([]string{"--user=1000", "--groups", "audio, video", "--", "sh", "-c", "id"})
+	require.NoError(t, err)
+	require.Equal(t, "1000", spec)
+	require.Equal(t, []string{"audio", "video"}, groups)
+	require.Equal(t, []string{"sh", "-c", "id"}, argv)
+
+	_, _, _, err = parseExecArgs([]string{"--", "id"})
+	require.Error(t, err)
+
+	_, _, _, err = parseExecArgs([]string{"--user", "app"})
+	require.Error(t, err)
+
+	_, _, _, err = parseExecArgs([]string{"--unknown", "app", "--", "id"})
+	require.Error(t, err)
+
+	require.Error(t, RunSuExec([]string{"app"}))
+}
@@ -0,0 +1,139 @@
+package mmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yankeguo/minit/internal/mexec"
+	"github.com/yankeguo/minit/internal/mrunners"
+	"github.com/yankeguo/minit/internal/munit"
+)
+
+var (
+	states = []string{
+		mrunners.StatePending,
+		mrunners.StateWaiting,
+		mrunners.StateRunning,
+		mrunners.StateSucceeded,
+		mrunners.StateFailed,
+		mrunners.StateRestarting,
+		mrunners.StateStopped,
+	}
+
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// Handler serves metrics of units and processes in Prometheus text format
+func Handler(sup *mrunners.Supervisor) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(rw)
+		Write(bw, sup.Units(), time.Now())
+		_ = bw.Flush()
+	})
+}
+
+type family struct {
+	name    string
+	help    string
+	typ     string
+	samples []string
+}
+
+func (f *family) add(labels []string, value float64) {
+	var pairs []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+`="`+labelEscaper.Replace(labels[i+1])+`"`)
+	}
+	f.samples = append(f.samples, f.name+"{"+strings.Join(pairs, ",")+"} "+strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func timestamp(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+// Write writes metrics of units in Prometheus text format, cpu and memory of processes are read from /proc
+func Write(w io.Writer, units []mrunners.UnitInfo, now time.Time) {
+	var (
+		fState = &family{name: "minit_unit_state", typ: "gauge", help: "Current state of the unit, 1 for the current state."}
+		fReady = &family{name: "minit_unit_ready", typ: "gauge", help: "Whether the unit is ready."}
+
+		fRestarts  = &family{name: "minit_unit_restarts_total", typ: "counter", help: "Number of restarts of the unit."}
+		fExitCode  = &family{name: "minit_unit_last_exit_code", typ: "gauge", help: "Exit code of the last finished process of the unit, -1 if killed by signal."}
+		fOOMKills  = &family{name: "minit_unit_oom_kills_total", typ: "counter", help: "Number of processes of the unit killed by the OOM killer."}
+		fDuration  = &family{name: "minit_unit_last_duration_seconds", typ: "gauge", help: "Wall-clock duration of the last finished process of the unit in seconds."}
+		fLastCPU   = &family{name: "minit_unit_last_cpu_seconds", typ: "gauge", help: "User and system CPU time of the last finished process of the unit in seconds."}
+		fLastRSS   = &family{name: "minit_unit_last_max_resident_memory_bytes", typ: "gauge", help: "Maximum resident memory size of the last finished process of the unit in bytes."}
+		fSinceLast = &family{name: "minit_unit_seconds_since_last_start", typ: "gauge", help: "Seconds since the last process of the unit started."}
+
+		fCronLast = &family{name: "minit_cron_last_run_timestamp_seconds", typ: "gauge", help: "Unix time of the last run of the cron unit."}
+		fCronNext = &family{name: "minit_cron_next_run_timestamp_seconds", typ: "gauge", help: "Unix time of the next scheduled run of the cron unit."}
+
+		fCPU = &family{name: "minit_process_cpu_seconds_total", typ: "counter", help: "User and system CPU time of the process in seconds."}
+		fRSS = &family{name: "minit_process_resident_memory_bytes", typ: "gauge", help: "Resident memory size of the process in bytes."}
+	)
+
+	for _, unit := range units {
+		labels := []string{"unit", unit.Name, "kind", unit.Kind}
+
+		for _, state := range states {
+			fState.add(append(labels, "state", state), boolValue(unit.State == state))
+		}
+		fReady.add(labels, boolValue(unit.Ready))
+		fRestarts.add(labels, float64(unit.Restarts))
+		fOOMKills.add(labels, float64(unit.OOMKills))
+
+		if unit.ExitCode != nil {
+			fExitCode.add(labels, float64(*unit.ExitCode))
+		}
+		if res := unit.LastResult; res != nil {
+			fDuration.add(labels, res.Duration.Seconds())
+			fLastCPU.add(labels, (res.UserTime + res.SystemTime).Seconds())
+			fLastRSS.add(labels, float64(res.MaxRSS))
+		}
+		if !unit.StartedAt.IsZero() {
+			fSinceLast.add(labels, now.Sub(unit.StartedAt).Seconds())
+		}
+
+		if unit.Kind == munit.KindCron {
+			if !unit.CronLast.IsZero() {
+				fCronLast.add(labels, timestamp(unit.CronLast))
+			}
+			if !unit.CronNext.IsZero() {
+				fCronNext.add(labels, timestamp(unit.CronNext))
+			}
+		}
+
+		for _, pid := range unit.PIDs {
+			stat, err := mexec.ReadProcStat(pid)
+			if err != nil {
+				continue
+			}
+			pLabels := append(labels, "pid", strconv.Itoa(pid))
+			fCPU.add(pLabels, stat.CPUTime.Seconds())
+			fRSS.add(pLabels, float64(stat.RSS))
+		}
+	}
+
+	for _, f := range []*family{fState, fReady, fRestarts, fOOMKills, fExitCode, fDuration, fLastCPU, fLastRSS, fSinceLast, fCronLast, fCronNext, fCPU, fRSS} {
+		if len(f.samples) == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.typ)
+		for _, sample := range f.samples {
+			_, _ = io.WriteString(w, sample+"\n")
+		}
+	}
+}
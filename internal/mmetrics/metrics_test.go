@@ -0,0 +1,71 @@
+package mmetrics
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yankeguo/minit/internal/mexec"
+	"github.com/yankeguo/minit/internal/mrunners"
+	"github.com/yankeguo/minit/internal/munit"
+)
+
+func TestWrite(t *testing.T) {
+	now := time.Unix(1000, 0)
+	code := 2
+
+	buf := &bytes.Buffer{}
+
+	Write(buf, []mrunners.UnitInfo{
+		{
+			Name: "web",
+			Kind: munit.KindDaemon,
+			StatusInfo: mrunners.StatusInfo{
+				State:     mrunners.StateRunning,
+				Ready:     true,
+				Restarts:  3,
+				OOMKills:  1,
+				ExitCode:  &code,
+				StartedAt: now.Add(-time.Second * 90),
+			},
+			PIDs: []int{os.Getpid()},
+		},
+		{
+			Name: "backup",
+			Kind: munit.KindCron,
+			StatusInfo: mrunners.StatusInfo{
+				State:    mrunners.StateRunning,
+				CronNext: time.Unix(1060, 0),
+				LastResult: &mexec.ExecuteResult{
+					Started:    true,
+					Duration:   time.Second * 3,
+					UserTime:   time.Second,
+					SystemTime: time.Millisecond * 500,
+					MaxRSS:     1048576,
+				},
+			},
+		},
+	}, now)
+
+	output := buf.String()
+
+	require.Contains(t, output, "# TYPE minit_unit_restarts_total counter\n")
+	require.Contains(t, output, `minit_unit_state{unit="web",kind="daemon",state="running"} 1`+"\n")
+	require.Contains(t, output, `minit_unit_state{unit="web",kind="daemon",state="failed"} 0`+"\n")
+	require.Contains(t, output, `minit_unit_ready{unit="web",kind="daemon"} 1`+"\n")
+	require.Contains(t, output, `minit_unit_restarts_total{unit="web",kind="daemon"} 3`+"\n")
+	require.Contains(t, output, `minit_unit_oom_kills_total{unit="web",kind="daemon"} 1`+"\n")
+	require.Contains(t, output, `minit_unit_last_exit_code{unit="web",kind="daemon"} 2`+"\n")
+	require.Contains(t, output, `minit_unit_seconds_since_last_start{unit="web",kind="daemon"} 90`+"\n")
+	require.Contains(t, output, `minit_cron_next_run_timestamp_seconds{unit="backup",kind="cron"} 1060`+"\n")
+	require.NotContains(t, output, "minit_cron_last_run_timestamp_seconds")
+	require.NotContains(t, output, `minit_unit_last_exit_code{unit="backup"`)
+	require.Contains(t, output, `minit_unit_last_duration_seconds{unit="backup",kind="cron"} 3`+"\n")
+	require.Contains(t, output, `minit_unit_last_cpu_seconds{unit="backup",kind="cron"} 1.5`+"\n")
+	require.Contains(t, output, `minit_unit_last_max_resident_memory_bytes{unit="backup",kind="cron"} 1.048576e+06`+"\n")
+	require.NotContains(t, output, `minit_unit_last_duration_seconds{unit="web"`)
+	require.Contains(t, output, `minit_process_resident_memory_bytes{unit="web",kind="daemon",pid="`)
+	require.Contains(t, output, `minit_process_cpu_seconds_total{unit="web",kind="daemon",pid="`)
+}
@@ -1,15 +1,23 @@
 package mrunners
 
 import (
+	"context"
+	"errors"
+	"strings"
+
 	"github.com/yankeguo/minit/internal/mexec"
 	"github.com/yankeguo/minit/internal/mlog"
 	"github.com/yankeguo/minit/internal/munit"
 )
 
 type RunnerOptions struct {
-	Unit   munit.Unit
-	Exec   mexec.Manager
-	Logger mlog.ProcLogger
+	Unit     munit.Unit
+	Exec     mexec.Manager
+	Logger   mlog.ProcLogger
+	Registry *Registry
+
+	// ReportError receives errors of runners still running in background after Do returned, e.g. non-blocking 'once'
+	ReportError func(err error)
 }
 
 func (ro RunnerOptions) Print(message string) {
@@ -28,8 +36,66 @@ func (ro RunnerOptions) Errorf(layout string, items ...any) {
 	ro.Logger.Errorf("minit: "+ro.Unit.Kind+"/"+ro.Unit.Name+": "+layout, items...)
 }
 
-func (ro RunnerOptions) Execute() error {
-	return ro.Exec.Execute(ro.Unit.ExecuteOptions(ro.Logger))
+// Execute executes the process of the unit, the process is stopped once ctx is done or 'timeout' of the unit reached
+func (ro RunnerOptions) Execute(ctx context.Context) (res mexec.ExecuteResult, err error) {
+	if ro.Unit.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, ro.Unit.Timeout, errors.New("timed out after "+ro.Unit.Timeout.String()))
+		defer cancel()
+	}
+
+	ro.Status().SetStarted()
+
+	res, err = ro.Exec.Execute(ctx, ro.Unit.ExecuteOptions(ro.Logger))
+
+	if res.Started {
+		ro.Status().SetResult(res)
+	}
+
+	if res.OOMKilled {
+		ro.Errorf("unit %s was OOM-killed", ro.Unit.Name)
+	}
+
+	return
+}
+
+// Watch supervises a running process not started by the unit, e.g. the daemon in 'pid_file', until it exited or ctx
+// is done
+func (ro RunnerOptions) Watch(ctx context.Context, pid int) (res mexec.ExecuteResult, err error) {
+	res, err = ro.Exec.Watch(ctx, ro.Unit.ExecuteOptions(ro.Logger), pid)
+
+	if res.Started {
+		ro.Status().SetResult(res)
+	}
+
+	if res.OOMKilled {
+		ro.Errorf("unit %s was OOM-killed", ro.Unit.Name)
+	}
+
+	return
+}
+
+// ExitEssential returns an ExitError with exit status of the process if the unit is essential, otherwise nil
+func (ro RunnerOptions) ExitEssential(res mexec.ExecuteResult) error {
+	if !ro.Unit.Essential {
+		return nil
+	}
+
+	// exit status is unknown if the process was not started, or a watched process disappeared
+	code := 1
+	if res.Started && res.ExitStatus() >= 0 {
+		code = res.ExitStatus()
+	}
+
+	ro.Printf("essential unit exited with code %d", code)
+
+	return &ExitError{Unit: ro.Unit.Name, Code: code}
+}
+
+func (ro RunnerOptions) reportError(err error) {
+	if err != nil && ro.ReportError != nil {
+		ro.ReportError(err)
+	}
 }
 
 func (ro RunnerOptions) PanicOnCritical(message string, err error) error {
@@ -45,3 +111,38 @@ func (ro RunnerOptions) PanicOnCritical(message string, err error) error {
 		return nil
 	}
 }
+
+func (ro RunnerOptions) Status() *Status {
+	return ro.Registry.Status(ro.Unit.Name)
+}
+
+// WaitDependencies waits for units in 'after' and 'requires' of the unit, returns false if the unit should not start,
+// either the context is cancelled or a required unit failed
+func (ro RunnerOptions) WaitDependencies(ctx context.Context) bool {
+	if len(ro.Unit.After) == 0 && len(ro.Unit.Requires) == 0 {
+		return true
+	}
+
+	ro.Status().SetState(StateWaiting)
+	ro.Print("waiting for dependencies: " + strings.Join(append(append([]string{}, ro.Unit.After...), ro.Unit.Requires...), ", "))
+
+	for _, name := range ro.Unit.After {
+		if ro.Registry.Status(name).Wait(ctx, false) != nil {
+			return false
+		}
+	}
+
+	for _, name := range ro.Unit.Requires {
+		if err := ro.Registry.Status(name).Wait(ctx, true); err != nil {
+			if ctx.Err() == nil {
+				ro.Status().SetFailed()
+				ro.PanicOnCritical("dependency failed", errors.New("required unit '"+name+"' failed"))
+			}
+			return false
+		}
+	}
+
+	ro.Print("dependencies satisfied")
+
+	return true
+}
@@ -0,0 +1,100 @@
+package mrunners
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"slices"
+	"time"
+
+	"github.com/yankeguo/minit/internal/mexec"
+	"github.com/yankeguo/minit/internal/munit"
+)
+
+// runProbe executes a single check of probe, with timeout, exec probe runs with user, environment and privileges of opts
+func runProbe(ctx context.Context, p munit.Probe, opts mexec.ExecuteOptions) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	switch {
+	case len(p.Exec) > 0:
+		var cmd *exec.Cmd
+		if cmd, err = mexec.Command(opts, p.Exec); err != nil {
+			err = fmt.Errorf("exec probe failed: %s", err.Error())
+			return
+		}
+		if err = mexec.RunCommand(ctx, cmd); err != nil {
+			err = fmt.Errorf("exec probe failed: %s", err.Error())
+		}
+	case p.TCP != "":
+		var conn net.Conn
+		if conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", p.TCP); err != nil {
+			err = fmt.Errorf("tcp probe failed: %s", err.Error())
+			return
+		}
+		_ = conn.Close()
+	case p.HTTP != "":
+		var req *http.Request
+		if req, err = http.NewRequestWithContext(ctx, http.MethodGet, p.HTTP, nil); err != nil {
+			err = fmt.Errorf("http probe failed: %s", err.Error())
+			return
+		}
+		var res *http.Response
+		if res, err = http.DefaultClient.Do(req); err != nil {
+			err = fmt.Errorf("http probe failed: %s", err.Error())
+			return
+		}
+		_ = res.Body.Close()
+		if len(p.HTTPStatus) > 0 {
+			if !slices.Contains(p.HTTPStatus, res.StatusCode) {
+				err = fmt.Errorf("http probe failed: unexpected status code %d", res.StatusCode)
+			}
+		} else if res.StatusCode < 200 || res.StatusCode >= 400 {
+			err = fmt.Errorf("http probe failed: unexpected status code %d", res.StatusCode)
+		}
+	default:
+		err = errors.New("invalid probe")
+	}
+	return
+}
+
+// loopProbe runs probe periodically until context is done, fn is invoked with result and count of consecutive
+// failures, loop stops if fn returns false
+func loopProbe(ctx context.Context, p munit.Probe, opts mexec.ExecuteOptions, fn func(err error, failures int) bool) {
+	p = p.WithDefaults()
+
+	delay := p.InitialDelay
+
+	var failures int
+
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		err := runProbe(ctx, p, opts)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == nil {
+			failures = 0
+		} else {
+			failures++
+		}
+
+		if !fn(err, failures) {
+			return
+		}
+
+		delay = p.Interval
+	}
+}
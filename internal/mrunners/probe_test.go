@@ -0,0 +1,84 @@
+package mrunners
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yankeguo/minit/internal/mexec"
+	"github.com/yankeguo/minit/internal/munit"
+)
+
+func TestRunProbe(t *testing.T) {
+	ctx := context.Background()
+
+	probe := func(p munit.Probe) munit.Probe {
+		return p.WithDefaults()
+	}
+
+	require.NoError(t, runProbe(ctx, probe(munit.Probe{Exec: []string{"true"}}), mexec.ExecuteOptions{}))
+	require.Error(t, runProbe(ctx, probe(munit.Probe{Exec: []string{"false"}}), mexec.ExecuteOptions{}))
+	require.Error(t, runProbe(ctx, probe(munit.Probe{Exec: []string{"sleep", "5"}, Timeout: time.Millisecond * 100}), mexec.ExecuteOptions{}))
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	require.NoError(t, runProbe(ctx, probe(munit.Probe{TCP: addr}), mexec.ExecuteOptions{}))
+	require.NoError(t, lis.Close())
+	require.Error(t, runProbe(ctx, probe(munit.Probe{TCP: addr}), mexec.ExecuteOptions{}))
+
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/ok" {
+			rw.WriteHeader(http.StatusNoContent)
+		} else {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer s.Close()
+
+	require.NoError(t, runProbe(ctx, probe(munit.Probe{HTTP: s.URL + "/ok"}), mexec.ExecuteOptions{}))
+	require.Error(t, runProbe(ctx, probe(munit.Probe{HTTP: s.URL + "/ok", HTTPStatus: []int{200}}), mexec.ExecuteOptions{}))
+	require.Error(t, runProbe(ctx, probe(munit.Probe{HTTP: s.URL + "/fail"}), mexec.ExecuteOptions{}))
+	require.NoError(t, runProbe(ctx, probe(munit.Probe{HTTP: s.URL + "/fail", HTTPStatus: []int{503}}), mexec.ExecuteOptions{}))
+}
+
+func TestRunProbeExecOptions(t *testing.T) {
+	ctx := context.Background()
+
+	p := munit.Probe{Exec: []string{"sh", "-c", `test "$PROBE" = hello && test "$(pwd)" = /tmp`}}.WithDefaults()
+
+	require.Error(t, runProbe(ctx, p, mexec.ExecuteOptions{}))
+	require.NoError(t, runProbe(ctx, p, mexec.ExecuteOptions{
+		Dir: "/tmp",
+		Env: map[string]string{"PROBE": "hello"},
+	}))
+
+	if os.Getuid() != 0 {
+		t.Skip("requires root")
+	}
+
+	p = munit.Probe{Exec: []string{"sh", "-c", `test "$(id -u):$(id -g)" = 65534:65534`}}.WithDefaults()
+
+	require.Error(t, runProbe(ctx, p, mexec.ExecuteOptions{}))
+	require.NoError(t, runProbe(ctx, p, mexec.ExecuteOptions{User: "65534:65534"}))
+}
+
+func TestLoopProbe(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	var results []int
+
+	loopProbe(ctx, munit.Probe{Exec: []string{"false"}, Interval: time.Millisecond * 50}, mexec.ExecuteOptions{}, func(err error, failures int) bool {
+		require.Error(t, err)
+		results = append(results, failures)
+		return failures < 3
+	})
+
+	require.Equal(t, []int{1, 2, 3}, results)
+}
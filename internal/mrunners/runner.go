@@ -3,7 +3,10 @@ package mrunners
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
+
+	"github.com/yankeguo/minit/internal/munit"
 )
 
 // RunnerAction is the interface of runner action
@@ -13,10 +16,22 @@ type RunnerAction interface {
 
 // Runner is the struct of runner
 type Runner struct {
+	Unit   munit.Unit
 	Long   bool
+	Status *Status
 	Action RunnerAction
 }
 
+// ExitError is returned by runners of essential units once the process exited, minit should exit with Code
+type ExitError struct {
+	Unit string
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("essential unit '%s' exited with code %d", e.Unit, e.Code)
+}
+
 var (
 	factories                 = map[string]RunnerFactory{}
 	factoriesLock sync.Locker = &sync.Mutex{}
@@ -34,12 +49,17 @@ func Register(name string, factory RunnerFactory) {
 }
 
 // Create creates a runner from options
-func Create(opts RunnerOptions) (Runner, error) {
+func Create(opts RunnerOptions) (runner Runner, err error) {
 	factoriesLock.Lock()
 	defer factoriesLock.Unlock()
 
 	if fac, ok := factories[opts.Unit.Kind]; ok {
-		return fac(opts)
+		if runner, err = fac(opts); err != nil {
+			return
+		}
+		runner.Unit = opts.Unit
+		runner.Status = opts.Status()
+		return
 	} else {
 		return Runner{}, errors.New("unknown runner kind: " + opts.Unit.Kind)
 	}
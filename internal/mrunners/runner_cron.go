@@ -3,6 +3,7 @@ package mrunners
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/robfig/cron/v3"
 	"github.com/yankeguo/minit/internal/munit"
@@ -36,8 +37,16 @@ func (r *actionCron) Do(ctx context.Context) (err error) {
 	defer r.Print("controller exited")
 	defer rg.Guard(&err)
 
+	if !r.WaitDependencies(ctx) {
+		return
+	}
+
+	r.Status().SetReady(StateRunning)
+
 	if r.Unit.Immediate {
-		err = r.PanicOnCritical("failed executing", r.Execute())
+		if _, execErr := r.Execute(ctx); ctx.Err() == nil {
+			err = r.PanicOnCritical("failed executing", execErr)
+		}
 	}
 
 	cr := cron.New(cron.WithLogger(cron.PrintfLogger(r.Logger)))
@@ -48,24 +57,34 @@ func (r *actionCron) Do(ctx context.Context) (err error) {
 		chErr = make(chan error, 1)
 	}
 
-	rg.Must(
-		cr.AddFunc(
-			r.Unit.Cron,
-			func() {
-				r.Print("triggered")
-				if err := func() (err error) {
-					defer rg.Guard(&err)
-					return r.PanicOnCritical("failed executing", r.Execute())
-				}(); err != nil {
-					if chErr != nil {
-						select {
-						case chErr <- err:
-						default:
-						}
+	schedule := rg.Must(cron.ParseStandard(r.Unit.Cron))
+
+	r.Status().SetCron(time.Time{}, schedule.Next(time.Now()))
+
+	cr.Schedule(
+		schedule,
+		cron.FuncJob(func() {
+			now := time.Now()
+			r.Status().SetCron(now, schedule.Next(now))
+
+			r.Print("triggered")
+			if err := func() (err error) {
+				defer rg.Guard(&err)
+				_, err = r.Execute(ctx)
+				// running job is stopped by shutdown or reload, not a failure of the unit
+				if ctx.Err() != nil {
+					return nil
+				}
+				return r.PanicOnCritical("failed executing", err)
+			}(); err != nil {
+				if chErr != nil {
+					select {
+					case chErr <- err:
+					default:
 					}
 				}
-			},
-		),
+			}
+		}),
 	)
 
 	cr.Start()
@@ -81,5 +100,7 @@ func (r *actionCron) Do(ctx context.Context) (err error) {
 
 	<-cr.Stop().Done()
 
+	r.Status().SetState(StateStopped)
+
 	return
 }
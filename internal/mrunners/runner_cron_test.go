@@ -16,7 +16,7 @@ import (
 )
 
 func TestRunnerCron(t *testing.T) {
-	exem := mexec.NewManager()
+	exem := mexec.NewManager(mexec.ManagerOptions{})
 
 	buf := &bytes.Buffer{}
 
@@ -60,7 +60,7 @@ func TestRunnerCron(t *testing.T) {
 }
 
 func TestRunnerCronCritical(t *testing.T) {
-	exem := mexec.NewManager()
+	exem := mexec.NewManager(mexec.ManagerOptions{})
 
 	buf := &bytes.Buffer{}
 
@@ -99,3 +99,36 @@ func TestRunnerCronCritical(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestRunnerCronStop(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	buf := &bytes.Buffer{}
+
+	r := &actionCron{
+		RunnerOptions: RunnerOptions{
+			Unit: munit.Unit{
+				Kind:      munit.KindCron,
+				Name:      "test",
+				Cron:      "@every 1h",
+				Immediate: true,
+				Critical:  true,
+				Command:   []string{"sleep", "10"},
+			},
+			Exec: exem,
+			Logger: rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{
+				ConsoleOut: buf,
+				ConsoleErr: buf,
+			})),
+		},
+	}
+
+	ctx, ctxCancel := context.WithTimeout(context.Background(), time.Millisecond*300)
+	defer ctxCancel()
+
+	t1 := time.Now()
+
+	// running job is stopped by cancellation, not a failure of critical unit
+	require.NoError(t, r.Do(ctx))
+	require.True(t, time.Since(t1) < time.Second*2)
+}
@@ -2,8 +2,16 @@ package mrunners
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/yankeguo/minit/internal/mexec"
 	"github.com/yankeguo/minit/internal/munit"
 	"github.com/yankeguo/rg"
 )
@@ -12,6 +20,8 @@ func init() {
 	Register(munit.KindDaemon, func(opts RunnerOptions) (runner Runner, err error) {
 		defer rg.Guard(&err)
 		rg.Must0(opts.Unit.RequireCommand())
+		rg.Must0(opts.Unit.RequireValidProbes())
+		rg.Must0(opts.Unit.RequireValidRestart())
 
 		runner.Long = true
 		runner.Action = &actionDaemon{RunnerOptions: opts}
@@ -19,6 +29,11 @@ func init() {
 	})
 }
 
+const (
+	pidFileTimeout  = time.Second * 10
+	pidFileInterval = time.Millisecond * 100
+)
+
 type actionDaemon struct {
 	RunnerOptions
 }
@@ -28,22 +43,68 @@ func (r *actionDaemon) Do(ctx context.Context) (err error) {
 	defer r.Print("controller exited")
 	defer rg.Guard(&err)
 
+	if !r.WaitDependencies(ctx) {
+		return
+	}
+
+	policy := r.Unit.Restart
+	if policy == "" {
+		policy = munit.RestartAlways
+	}
+
+	bo := newBackoff(r.Unit)
+
+	sl := newStartLimit(r.Unit)
+
 forLoop:
 	for {
 		if ctx.Err() != nil {
 			break forLoop
 		}
 
-		err = r.PanicOnCritical("failed executing", r.Execute())
+		if !sl.allow(time.Now()) {
+			r.Status().SetFailed()
+			err = r.PanicOnCritical("start limit reached, unit failed", fmt.Errorf("started %d times within %s", sl.burst, sl.interval))
+			return
+		}
+
+		startedAt := time.Now()
+
+		res, execErr := r.execute(ctx)
 
 		if ctx.Err() != nil {
 			break forLoop
 		}
 
-		r.Print("restarting")
+		if r.Unit.Essential {
+			r.Status().SetDone(execErr)
+			err = r.ExitEssential(res)
+			return
+		}
+
+		err = r.PanicOnCritical("failed executing", execErr)
+
+		if policy == munit.RestartNever || (policy == munit.RestartOnFailure && execErr == nil) {
+			r.Print("not restarting, restart policy: " + policy)
+			r.Status().SetDone(execErr)
+			return
+		}
+
+		// process has been running stably, start over the backoff
+		if time.Since(startedAt) >= bo.resetAfter {
+			bo.reset()
+		}
+
+		delay := bo.next()
+
+		r.Status().SetState(StateRestarting)
+
+		reason := restartReason(res, execErr)
+
+		r.Printf("restarting in %s, reason: %s", delay, reason)
 
 		// Create timer for restart delay with proper cleanup
-		timer := time.NewTimer(time.Second * 5)
+		timer := time.NewTimer(delay)
 		select {
 		case <-timer.C:
 			// Timer expired naturally
@@ -55,7 +116,244 @@ forLoop:
 			}
 			break forLoop
 		}
+
+		r.Status().AddRestart(reason)
 	}
 
+	r.Status().SetState(StateStopped)
+
 	return
 }
+
+// restartReason returns reason of restarting the process, one of RestartReason*
+func restartReason(res mexec.ExecuteResult, err error) string {
+	switch {
+	case res.OOMKilled:
+		return RestartReasonOOMKilled
+	case err != nil:
+		return RestartReasonFailed
+	default:
+		return RestartReasonExited
+	}
+}
+
+// startLimit limits starts of a unit within a sliding window
+type startLimit struct {
+	burst    int
+	interval time.Duration
+	starts   []time.Time
+}
+
+func newStartLimit(unit munit.Unit) *startLimit {
+	sl := &startLimit{
+		burst:    unit.StartLimitBurst,
+		interval: unit.StartLimitInterval,
+	}
+	if sl.interval == 0 {
+		sl.interval = munit.DefaultStartLimitInterval
+	}
+	return sl
+}
+
+// allow records a start at now, returns false if the unit has been started 'burst' times within the interval
+func (sl *startLimit) allow(now time.Time) bool {
+	if sl.burst <= 0 {
+		return true
+	}
+
+	var starts []time.Time
+	for _, t := range sl.starts {
+		if now.Sub(t) < sl.interval {
+			starts = append(starts, t)
+		}
+	}
+	sl.starts = starts
+
+	if len(sl.starts) >= sl.burst {
+		return false
+	}
+
+	sl.starts = append(sl.starts, now)
+	return true
+}
+
+// backoff calculates exponential delays between restarts
+type backoff struct {
+	initial    time.Duration
+	max        time.Duration
+	multiplier float64
+	resetAfter time.Duration
+
+	current time.Duration
+}
+
+func newBackoff(unit munit.Unit) *backoff {
+	bo := &backoff{
+		initial:    unit.RestartDelay,
+		max:        unit.RestartMaxDelay,
+		multiplier: unit.RestartMultiplier,
+		resetAfter: unit.RestartResetAfter,
+	}
+	if bo.initial == 0 {
+		bo.initial = munit.DefaultRestartDelay
+	}
+	if bo.max == 0 {
+		bo.max = munit.DefaultRestartMaxDelay
+	}
+	if bo.max < bo.initial {
+		bo.max = bo.initial
+	}
+	if bo.multiplier == 0 {
+		bo.multiplier = munit.DefaultRestartMultiplier
+	}
+	if bo.resetAfter == 0 {
+		bo.resetAfter = munit.DefaultRestartResetAfter
+	}
+	return bo
+}
+
+// next returns the next delay
+func (bo *backoff) next() time.Duration {
+	if bo.current == 0 {
+		bo.current = bo.initial
+	} else if next := float64(bo.current) * bo.multiplier; next < float64(bo.max) {
+		bo.current = time.Duration(next)
+	} else {
+		bo.current = bo.max
+	}
+	return bo.current
+}
+
+// reset resets the delay to initial value
+func (bo *backoff) reset() {
+	bo.current = 0
+}
+
+// execute executes the process once, with readiness and liveness probes running
+func (r *actionDaemon) execute(ctx context.Context) (mexec.ExecuteResult, error) {
+	// every process starts unready, unless there is no readiness probe
+	if r.Unit.Readiness == nil {
+		r.Status().SetReady(StateRunning)
+	} else {
+		r.Status().SetState(StateRunning)
+	}
+	defer r.Status().SetUnready()
+
+	if r.Unit.Readiness == nil && r.Unit.Liveness == nil {
+		return r.run(ctx)
+	}
+
+	// probes stop as soon as the process exited
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// exec probes run as the unit does
+	opts := r.Unit.ExecuteOptions(r.Logger)
+
+	if r.Unit.Readiness != nil {
+		go r.probeReadiness(ctx, opts)
+	}
+
+	if r.Unit.Liveness != nil {
+		go r.probeLiveness(ctx, opts)
+	}
+
+	return r.run(ctx)
+}
+
+// run executes the process, and supervises the process in 'pid_file' once the command exited successfully
+func (r *actionDaemon) run(ctx context.Context) (mexec.ExecuteResult, error) {
+	res, err := r.Execute(ctx)
+
+	if r.Unit.PIDFile == "" || err != nil || ctx.Err() != nil {
+		return res, err
+	}
+
+	pid, err := r.readPIDFile(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.Error("failed reading pid file: " + err.Error())
+		}
+		return mexec.ExecuteResult{}, err
+	}
+
+	r.Printf("supervising process %d from pid file %s", pid, r.Unit.PIDFile)
+
+	return r.Watch(ctx, pid)
+}
+
+// readPIDFile waits for 'pid_file' to contain pid of a running process, the command may exit before the forked
+// daemon wrote it
+func (r *actionDaemon) readPIDFile(ctx context.Context) (pid int, err error) {
+	file := filepath.Join(r.Unit.Chroot, r.Unit.PIDFile)
+
+	ctx, cancel := context.WithTimeout(ctx, pidFileTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(pidFileInterval)
+	defer ticker.Stop()
+
+	for {
+		var buf []byte
+		if buf, err = os.ReadFile(file); err == nil {
+			if pid, err = strconv.Atoi(strings.TrimSpace(string(buf))); err == nil {
+				if pid <= 0 {
+					err = errors.New("invalid pid " + strconv.Itoa(pid))
+				} else if err = syscall.Kill(pid, 0); err == nil || err == syscall.EPERM {
+					return pid, nil
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			err = fmt.Errorf("no running process in %s: %w", r.Unit.PIDFile, err)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *actionDaemon) probeReadiness(ctx context.Context, opts mexec.ExecuteOptions) {
+	p := r.Unit.Readiness.WithDefaults()
+
+	loopProbe(ctx, p, opts, func(err error, failures int) bool {
+		if err == nil {
+			if !r.Status().Ready() {
+				r.Print("readiness probe succeeded, unit is ready")
+				r.Status().SetReady(StateRunning)
+			}
+			return true
+		}
+
+		r.Errorf("readiness probe failed (%d/%d): %s", failures, p.FailureThreshold, err.Error())
+
+		if failures >= p.FailureThreshold && r.Status().Ready() {
+			r.Print("unit is not ready")
+			r.Status().SetUnready()
+		}
+		return true
+	})
+}
+
+func (r *actionDaemon) probeLiveness(ctx context.Context, opts mexec.ExecuteOptions) {
+	p := r.Unit.Liveness.WithDefaults()
+
+	loopProbe(ctx, p, opts, func(err error, failures int) bool {
+		if err == nil {
+			return true
+		}
+
+		r.Errorf("liveness probe failed (%d/%d): %s", failures, p.FailureThreshold, err.Error())
+
+		if failures < p.FailureThreshold {
+			return true
+		}
+
+		r.Print("liveness probe failed, stopping process")
+
+		r.Exec.Stop(r.Unit.ID(), syscall.SIGTERM)
+
+		return false
+	})
+}
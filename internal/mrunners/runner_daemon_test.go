@@ -3,6 +3,13 @@ package mrunners
 import (
 	"bytes"
 	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -15,7 +22,7 @@ import (
 )
 
 func TestRunnerDaemon(t *testing.T) {
-	exem := mexec.NewManager()
+	exem := mexec.NewManager(mexec.ManagerOptions{})
 
 	buf := &bytes.Buffer{}
 
@@ -60,7 +67,7 @@ func TestRunnerDaemon(t *testing.T) {
 }
 
 func TestRunnerDaemonCritical(t *testing.T) {
-	exem := mexec.NewManager()
+	exem := mexec.NewManager(mexec.ManagerOptions{})
 
 	buf := &bytes.Buffer{}
 
@@ -101,3 +108,497 @@ func TestRunnerDaemonCritical(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestRunnerDaemonRequires(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	buf := &bytes.Buffer{}
+
+	registry := NewRegistry()
+
+	r := &actionDaemon{
+		RunnerOptions: RunnerOptions{
+			Unit: munit.Unit{
+				Kind:     munit.KindDaemon,
+				Name:     "test",
+				Requires: []string{"dep"},
+				Command: []string{
+					"echo", "hello",
+				},
+			},
+			Exec:     exem,
+			Registry: registry,
+			Logger: rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{
+				ConsoleOut: buf,
+				ConsoleErr: buf,
+			})),
+		},
+	}
+
+	go func() {
+		time.Sleep(time.Millisecond * 500)
+		registry.Status("dep").SetFailed()
+	}()
+
+	err := r.Do(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, StateFailed, registry.Status("test").State())
+	require.Contains(t, buf.String(), "required unit 'dep' failed")
+	require.NotContains(t, buf.String(), "hello\n")
+}
+
+func TestRunnerDaemonProbes(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	buf := &bytes.Buffer{}
+
+	registry := NewRegistry()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	r := &actionDaemon{
+		RunnerOptions: RunnerOptions{
+			Unit: munit.Unit{
+				Kind: munit.KindDaemon,
+				Name: "test",
+				Command: []string{
+					"sleep", "100",
+				},
+				Readiness: &munit.Probe{
+					TCP:      lis.Addr().String(),
+					Interval: time.Millisecond * 100,
+				},
+				Liveness: &munit.Probe{
+					Exec:             []string{"false"},
+					InitialDelay:     time.Second,
+					Interval:         time.Millisecond * 100,
+					FailureThreshold: 2,
+				},
+			},
+			Exec:     exem,
+			Registry: registry,
+			Logger: rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{
+				ConsoleOut: buf,
+				ConsoleErr: buf,
+			})),
+		},
+	}
+
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	defer ctxCancel()
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := r.Do(ctx)
+		require.NoError(t, err)
+	}()
+
+	time.Sleep(time.Millisecond * 500)
+
+	require.True(t, registry.Status("test").Ready())
+
+	time.Sleep(time.Millisecond * 1000)
+
+	require.False(t, registry.Status("test").Ready())
+	require.Equal(t, StateRestarting, registry.Status("test").State())
+
+	ctxCancel()
+
+	wg.Wait()
+
+	require.Contains(t, buf.String(), "liveness probe failed, stopping process")
+	require.Contains(t, buf.String(), "restarting")
+}
+
+func TestRunnerDaemonReadyAfterRestart(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	registry := NewRegistry()
+
+	r := &actionDaemon{
+		RunnerOptions: RunnerOptions{
+			Unit: munit.Unit{
+				Kind:         munit.KindDaemon,
+				Name:         "test",
+				Restart:      munit.RestartAlways,
+				RestartDelay: time.Second,
+				Command: []string{
+					"sleep", "0.2",
+				},
+			},
+			Exec:     exem,
+			Registry: registry,
+			Logger: rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{
+				ConsoleOut: &bytes.Buffer{},
+				ConsoleErr: &bytes.Buffer{},
+			})),
+		},
+	}
+
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	defer ctxCancel()
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.Do(ctx)
+	}()
+
+	time.Sleep(time.Millisecond * 100)
+
+	require.True(t, registry.Status("test").Ready())
+
+	time.Sleep(time.Millisecond * 500)
+
+	require.False(t, registry.Status("test").Ready())
+	require.Equal(t, StateRestarting, registry.Status("test").State())
+
+	ctxCancel()
+
+	wg.Wait()
+}
+
+func TestBackoff(t *testing.T) {
+	bo := newBackoff(munit.Unit{})
+	require.Equal(t, munit.DefaultRestartDelay, bo.next())
+	require.Equal(t, munit.DefaultRestartDelay*2, bo.next())
+
+	bo = newBackoff(munit.Unit{
+		RestartDelay:      time.Second,
+		RestartMaxDelay:   time.Second * 5,
+		RestartMultiplier: 3,
+	})
+	require.Equal(t, time.Second, bo.next())
+	require.Equal(t, time.Second*3, bo.next())
+	require.Equal(t, time.Second*5, bo.next())
+	require.Equal(t, time.Second*5, bo.next())
+	bo.reset()
+	require.Equal(t, time.Second, bo.next())
+}
+
+func TestRunnerDaemonRestartPolicy(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	buf := &bytes.Buffer{}
+
+	registry := NewRegistry()
+
+	r := &actionDaemon{
+		RunnerOptions: RunnerOptions{
+			Unit: munit.Unit{
+				Kind:    munit.KindDaemon,
+				Name:    "test",
+				Restart: munit.RestartOnFailure,
+				Command: []string{
+					"echo", "hello",
+				},
+			},
+			Exec:     exem,
+			Registry: registry,
+			Logger: rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{
+				ConsoleOut: buf,
+				ConsoleErr: buf,
+			})),
+		},
+	}
+
+	err := r.Do(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, StateSucceeded, registry.Status("test").State())
+	require.Contains(t, buf.String(), "not restarting, restart policy: on-failure")
+
+	buf.Reset()
+
+	r.Unit.Command = []string{"false"}
+	r.Unit.RestartDelay = time.Millisecond * 100
+	r.Unit.RestartMaxDelay = time.Millisecond * 400
+
+	ctx, ctxCancel := context.WithTimeout(context.Background(), time.Millisecond*1000)
+	defer ctxCancel()
+
+	err = r.Do(ctx)
+	require.NoError(t, err)
+
+	// 100ms, 200ms, 400ms, 400ms
+	require.Equal(t, 4, strings.Count(buf.String(), "restarting in"))
+	require.Contains(t, buf.String(), "restarting in 400ms")
+}
+
+func TestRunnerDaemonPIDFile(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	buf := &bytes.Buffer{}
+
+	registry := NewRegistry()
+
+	file := filepath.Join(t.TempDir(), "test.pid")
+
+	r := &actionDaemon{
+		RunnerOptions: RunnerOptions{
+			Unit: munit.Unit{
+				Kind:    munit.KindDaemon,
+				Name:    "test",
+				Restart: munit.RestartNever,
+				Shell:   "/bin/sh",
+				Command: []string{
+					// launcher exits before the forked daemon writes pid file
+					"(sleep 0.3; sh -c 'echo $$ > " + file + "; exec sleep 1') > /dev/null 2>&1 &",
+				},
+				PIDFile: file,
+			},
+			Exec:     exem,
+			Registry: registry,
+			Logger: rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{
+				ConsoleOut: buf,
+				ConsoleErr: buf,
+			})),
+		},
+	}
+
+	t1 := time.Now()
+
+	err := r.Do(context.Background())
+	require.NoError(t, err)
+	require.True(t, time.Since(t1) >= time.Second)
+
+	// exit status of a process not adopted by minit is unknown
+	require.Equal(t, StateFailed, registry.Status("test").State())
+	require.Contains(t, buf.String(), "supervising process")
+	require.Contains(t, buf.String(), "exit status is unknown")
+
+	// no running process in pid file
+	require.NoError(t, os.WriteFile(file, []byte("0\n"), 0644))
+
+	ctx, ctxCancel := context.WithTimeout(context.Background(), time.Millisecond*300)
+	defer ctxCancel()
+
+	_, err = r.readPIDFile(ctx)
+	require.ErrorContains(t, err, "no running process in "+file)
+	require.ErrorContains(t, err, "invalid pid 0")
+}
+
+func TestRunnerDaemonPIDFileKillMode(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	for _, mode := range []string{mexec.KillModeGroup, mexec.KillModeMixed} {
+		buf := &bytes.Buffer{}
+
+		file := filepath.Join(t.TempDir(), "test.pid")
+
+		r := &actionDaemon{
+			RunnerOptions: RunnerOptions{
+				Unit: munit.Unit{
+					Kind:     munit.KindDaemon,
+					Name:     "test",
+					Restart:  munit.RestartNever,
+					KillMode: mode,
+					Shell:    "/bin/sh",
+					Command: []string{
+						// forked daemon stays in the process group of the launcher
+						"(sleep 0.3; sh -c 'echo $$ > " + file + "; exec sleep 1') > /dev/null 2>&1 &",
+					},
+					PIDFile: file,
+				},
+				Exec:     exem,
+				Registry: NewRegistry(),
+				Logger: rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{
+					ConsoleOut: buf,
+					ConsoleErr: buf,
+				})),
+			},
+		}
+
+		t1 := time.Now()
+
+		// daemon is not killed with the group once the launcher exited
+		require.NoError(t, r.Do(context.Background()))
+		require.True(t, time.Since(t1) >= time.Second, mode)
+		require.Contains(t, buf.String(), "supervising process", mode)
+	}
+}
+
+func TestRunnerDaemonPIDFileStopGroup(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	buf := &bytes.Buffer{}
+
+	file := filepath.Join(t.TempDir(), "test.pid")
+
+	r := &actionDaemon{
+		RunnerOptions: RunnerOptions{
+			Unit: munit.Unit{
+				Kind:        munit.KindDaemon,
+				Name:        "test",
+				Restart:     munit.RestartNever,
+				KillMode:    mexec.KillModeGroup,
+				StopTimeout: time.Second,
+				Shell:       "/bin/sh",
+				Command: []string{
+					// double-forked daemon is not the leader of its process group
+					"setsid sh -c 'sleep 30 & echo $! > " + file + "' > /dev/null 2>&1",
+				},
+				PIDFile: file,
+			},
+			Exec:     exem,
+			Registry: NewRegistry(),
+			Logger: rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{
+				ConsoleOut: buf,
+				ConsoleErr: buf,
+			})),
+		},
+	}
+
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	defer ctxCancel()
+
+	chErr := make(chan error, 1)
+	go func() {
+		chErr <- r.Do(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		buf, err := os.ReadFile(file)
+		if err != nil {
+			return false
+		}
+		pid, _ := strconv.Atoi(strings.TrimSpace(string(buf)))
+		return slices.Equal(exem.PIDs("daemon/test"), []int{pid})
+	}, time.Second*3, time.Millisecond*50)
+
+	t1 := time.Now()
+
+	ctxCancel()
+
+	select {
+	case err := <-chErr:
+		require.NoError(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("forked daemon was not stopped")
+	}
+	require.True(t, time.Since(t1) < time.Second)
+}
+
+func TestRestartReason(t *testing.T) {
+	require.Equal(t, RestartReasonExited, restartReason(mexec.ExecuteResult{Started: true}, nil))
+	require.Equal(t, RestartReasonFailed, restartReason(mexec.ExecuteResult{Started: true, ExitCode: 1}, errors.New("failed")))
+	require.Equal(t, RestartReasonOOMKilled, restartReason(mexec.ExecuteResult{Started: true, ExitCode: -1, OOMKilled: true}, errors.New("killed")))
+}
+
+func TestStartLimit(t *testing.T) {
+	sl := newStartLimit(munit.Unit{})
+	for range 10 {
+		require.True(t, sl.allow(time.Now()))
+	}
+
+	now := time.Now()
+	sl = newStartLimit(munit.Unit{StartLimitBurst: 2, StartLimitInterval: time.Second})
+	require.True(t, sl.allow(now))
+	require.True(t, sl.allow(now.Add(time.Millisecond*500)))
+	require.False(t, sl.allow(now.Add(time.Millisecond*900)))
+	require.True(t, sl.allow(now.Add(time.Millisecond*1100)))
+}
+
+func TestRunnerDaemonStartLimit(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	buf := &bytes.Buffer{}
+
+	registry := NewRegistry()
+
+	r := &actionDaemon{
+		RunnerOptions: RunnerOptions{
+			Unit: munit.Unit{
+				Kind:               munit.KindDaemon,
+				Name:               "test",
+				RestartDelay:       time.Millisecond * 50,
+				RestartMultiplier:  1,
+				StartLimitBurst:    3,
+				StartLimitInterval: time.Second * 10,
+				Command: []string{
+					"false",
+				},
+			},
+			Exec:     exem,
+			Registry: registry,
+			Logger: rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{
+				ConsoleOut: buf,
+				ConsoleErr: buf,
+			})),
+		},
+	}
+
+	err := r.Do(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, StateFailed, registry.Status("test").State())
+	require.Equal(t, 3, strings.Count(buf.String(), "restarting in"))
+	require.Contains(t, buf.String(), "start limit reached, unit failed")
+
+	r.Unit.Critical = true
+	r.Unit.Command = []string{"true"}
+
+	err = r.Do(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "started 3 times within 10s")
+}
+
+func TestRunnerDaemonEssential(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	buf := &bytes.Buffer{}
+
+	registry := NewRegistry()
+
+	r := &actionDaemon{
+		RunnerOptions: RunnerOptions{
+			Unit: munit.Unit{
+				Kind:      munit.KindDaemon,
+				Name:      "test",
+				Shell:     "/bin/bash",
+				Essential: true,
+				Command: []string{
+					"kill -TERM $$",
+				},
+			},
+			Exec:     exem,
+			Registry: registry,
+			Logger: rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{
+				ConsoleOut: buf,
+				ConsoleErr: buf,
+			})),
+		},
+	}
+
+	err := r.Do(context.Background())
+	var ee *ExitError
+	require.ErrorAs(t, err, &ee)
+	require.Equal(t, 128+15, ee.Code)
+	require.Equal(t, StateFailed, registry.Status("test").State())
+	require.NotContains(t, buf.String(), "restarting")
+}
+
+func TestExitEssential(t *testing.T) {
+	ro := RunnerOptions{
+		Unit:   munit.Unit{Kind: munit.KindDaemon, Name: "test", Essential: true},
+		Logger: rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{})),
+	}
+
+	var ee *ExitError
+
+	require.ErrorAs(t, ro.ExitEssential(mexec.ExecuteResult{Started: true, ExitCode: 3}), &ee)
+	require.Equal(t, 3, ee.Code)
+
+	require.ErrorAs(t, ro.ExitEssential(mexec.ExecuteResult{}), &ee)
+	require.Equal(t, 1, ee.Code)
+
+	// watched process of pid file disappeared
+	require.ErrorAs(t, ro.ExitEssential(mexec.ExecuteResult{Started: true, ExitCode: -1}), &ee)
+	require.Equal(t, 1, ee.Code)
+
+	ro.Unit.Essential = false
+	require.NoError(t, ro.ExitEssential(mexec.ExecuteResult{Started: true, ExitCode: 3}))
+}
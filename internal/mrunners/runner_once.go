@@ -29,13 +29,41 @@ func (r *actionOnce) Do(ctx context.Context) (err error) {
 	if r.Unit.Blocking != nil && !*r.Unit.Blocking {
 		go func() {
 			var err error
+			defer func() {
+				if ctx.Err() == nil {
+					r.reportError(err)
+				}
+			}()
 			defer rg.Guard(&err)
-			err = r.PanicOnCritical("failed executing (non-blocking)", r.Execute())
+			err = r.run(ctx, "failed executing (non-blocking)")
 		}()
 		return
 	}
 
-	err = r.PanicOnCritical("failed executing", r.Execute())
+	err = r.run(ctx, "failed executing")
 
 	return
 }
+
+func (r *actionOnce) run(ctx context.Context, message string) (err error) {
+	if !r.WaitDependencies(ctx) {
+		return
+	}
+
+	r.Status().SetState(StateRunning)
+
+	res, err := r.Execute(ctx)
+
+	r.Status().SetDone(err)
+
+	// stopped by shutdown or reload, not a failure of the unit
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	if exitErr := r.ExitEssential(res); exitErr != nil {
+		return exitErr
+	}
+
+	return r.PanicOnCritical(message, err)
+}
@@ -14,7 +14,7 @@ import (
 )
 
 func TestRunnerOnce(t *testing.T) {
-	exem := mexec.NewManager()
+	exem := mexec.NewManager(mexec.ManagerOptions{})
 
 	buf := &bytes.Buffer{}
 
@@ -43,7 +43,7 @@ func TestRunnerOnce(t *testing.T) {
 }
 
 func TestRunnerOnceCritical(t *testing.T) {
-	exem := mexec.NewManager()
+	exem := mexec.NewManager(mexec.ManagerOptions{})
 
 	buf := &bytes.Buffer{}
 
@@ -72,7 +72,7 @@ func TestRunnerOnceCritical(t *testing.T) {
 }
 
 func TestRunnerOnceCriticalNonBlocking(t *testing.T) {
-	exem := mexec.NewManager()
+	exem := mexec.NewManager(mexec.ManagerOptions{})
 
 	buf := &bytes.Buffer{}
 
@@ -105,3 +105,83 @@ func TestRunnerOnceCriticalNonBlocking(t *testing.T) {
 	require.NoError(t, err)
 	require.True(t, time.Since(start) < time.Millisecond*100)
 }
+
+func TestRunnerOnceEssential(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	buf := &syncBuffer{}
+
+	blocking := false
+
+	reported := make(chan error, 1)
+
+	r := &actionOnce{
+		RunnerOptions: RunnerOptions{
+			Unit: munit.Unit{
+				Kind:      munit.KindOnce,
+				Name:      "test",
+				Shell:     "/bin/bash",
+				Essential: true,
+				Critical:  true,
+				Command: []string{
+					"exit 3",
+				},
+			},
+			Exec: exem,
+			Logger: rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{
+				ConsoleOut: buf,
+				ConsoleErr: buf,
+			})),
+			ReportError: func(err error) {
+				reported <- err
+			},
+		},
+	}
+
+	err := r.Do(context.Background())
+	var ee *ExitError
+	require.ErrorAs(t, err, &ee)
+	require.Equal(t, 3, ee.Code)
+
+	r.Unit.Blocking = &blocking
+	r.Unit.Command = []string{"sleep 0.2 && exit 0"}
+
+	require.NoError(t, r.Do(context.Background()))
+	select {
+	case err = <-reported:
+		require.ErrorAs(t, err, &ee)
+		require.Equal(t, 0, ee.Code)
+	case <-time.After(time.Second * 3):
+		t.Fatal("no error reported")
+	}
+}
+
+func TestRunnerOnceTimeout(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	buf := &bytes.Buffer{}
+
+	r := &actionOnce{
+		RunnerOptions: RunnerOptions{
+			Unit: munit.Unit{
+				Kind:     munit.KindOnce,
+				Name:     "test",
+				Command:  []string{"sleep", "10"},
+				Critical: true,
+				Timeout:  time.Millisecond * 300,
+			},
+			Exec: exem,
+			Logger: rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{
+				ConsoleOut: buf,
+				ConsoleErr: buf,
+			})),
+		},
+	}
+
+	t1 := time.Now()
+
+	err := r.Do(context.Background())
+	require.Error(t, err)
+	require.True(t, time.Since(t1) < time.Second*2)
+	require.Contains(t, buf.String(), "process stopped: timed out after 300ms")
+}
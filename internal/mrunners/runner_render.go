@@ -160,8 +160,17 @@ func (r *actionRender) addTask1(tasks map[[2]string]struct{}, filePattern string
 func (r *actionRender) Do(ctx context.Context) (err error) {
 	r.Print("controller started")
 	defer r.Print("controller exited")
+	defer func() {
+		r.Status().SetDone(err)
+	}()
 	defer rg.Guard(&err)
 
+	if !r.WaitDependencies(ctx) {
+		return
+	}
+
+	r.Status().SetState(StateRunning)
+
 	var env map[string]string
 
 	if env, err = menv.Construct(menv.Environ(), r.Unit.Env); err != nil {
@@ -25,7 +25,7 @@ func TestRunnerRender(t *testing.T) {
 
 	dirDst := t.TempDir()
 
-	exem := mexec.NewManager()
+	exem := mexec.NewManager(mexec.ManagerOptions{})
 
 	r := &actionRender{
 		RunnerOptions: RunnerOptions{
@@ -0,0 +1,244 @@
+package mrunners
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/yankeguo/minit/internal/mexec"
+)
+
+const (
+	StatePending    = "pending"
+	StateWaiting    = "waiting"
+	StateRunning    = "running"
+	StateSucceeded  = "succeeded"
+	StateFailed     = "failed"
+	StateRestarting = "restarting"
+	StateStopped    = "stopped"
+)
+
+const (
+	RestartReasonExited    = "exited"     // process exited with a success code
+	RestartReasonFailed    = "failed"     // process failed, e.g. exit code not in 'success_codes'
+	RestartReasonOOMKilled = "oom-killed" // process was killed by the OOM killer
+)
+
+var (
+	ErrUnitFailed = errors.New("unit failed")
+)
+
+// StatusInfo is a snapshot of Status
+type StatusInfo struct {
+	State         string               `json:"state"`
+	Ready         bool                 `json:"ready"`
+	Restarts      int                  `json:"restarts"`
+	RestartReason string               `json:"restart_reason,omitempty"` // reason of the last restart, one of RestartReason*
+	OOMKills      int                  `json:"oom_kills"`                // number of processes killed by the OOM killer
+	ExitCode      *int                 `json:"exit_code,omitempty"`      // exit code of the last finished process, nil if none finished
+	LastResult    *mexec.ExecuteResult `json:"last_result,omitempty"`    // result of the last finished process, nil if none finished
+	StartedAt     time.Time            `json:"started_at"`               // start time of the last process
+	CronLast      time.Time            `json:"cron_last"`                // last triggered time, for 'cron' units only
+	CronNext      time.Time            `json:"cron_next"`                // next scheduled time, for 'cron' units only
+}
+
+// Status is the runtime status of a unit, shared between the runner of the unit and runners depending on it
+type Status struct {
+	mu      sync.Mutex
+	info    StatusInfo
+	failed  bool
+	changed chan struct{}
+}
+
+func newStatus() *Status {
+	return &Status{
+		info:    StatusInfo{State: StatePending},
+		changed: make(chan struct{}),
+	}
+}
+
+func (s *Status) update(fn func()) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn()
+
+	// wake up all waiters
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// State returns the current state
+func (s *Status) State() string {
+	if s == nil {
+		return StatePending
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.info.State
+}
+
+// Info returns a snapshot of the status
+func (s *Status) Info() StatusInfo {
+	if s == nil {
+		return StatusInfo{State: StatePending}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.info
+}
+
+// SetState updates the current state, without changing readiness
+func (s *Status) SetState(state string) {
+	s.update(func() {
+		s.info.State = state
+	})
+}
+
+// SetStarted records the start time of a process, a failed unit is no longer failed once started again
+func (s *Status) SetStarted() {
+	s.update(func() {
+		s.info.StartedAt = time.Now()
+		s.failed = false
+	})
+}
+
+// SetResult records the exit code and result of the last finished process
+func (s *Status) SetResult(res mexec.ExecuteResult) {
+	s.update(func() {
+		s.info.ExitCode = &res.ExitCode
+		s.info.LastResult = &res
+		if res.OOMKilled {
+			s.info.OOMKills++
+		}
+	})
+}
+
+// AddRestart increases the restart count, and records the reason, one of RestartReason*
+func (s *Status) AddRestart(reason string) {
+	s.update(func() {
+		s.info.Restarts++
+		s.info.RestartReason = reason
+	})
+}
+
+// SetCron records the last and next triggered time of a 'cron' unit
+func (s *Status) SetCron(last time.Time, next time.Time) {
+	s.update(func() {
+		s.info.CronLast = last
+		s.info.CronNext = next
+	})
+}
+
+// SetReady marks the unit as ready, units requiring or after this unit can proceed
+func (s *Status) SetReady(state string) {
+	s.update(func() {
+		s.info.State = state
+		s.info.Ready = true
+		s.failed = false
+	})
+}
+
+// SetUnready marks the unit as not ready, e.g. readiness probe failed
+func (s *Status) SetUnready() {
+	s.update(func() {
+		s.info.Ready = false
+	})
+}
+
+// Ready returns true if the unit is ready
+func (s *Status) Ready() bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.info.Ready
+}
+
+// SetFailed marks the unit as failed, units requiring this unit will fail, units after this unit can proceed
+func (s *Status) SetFailed() {
+	s.update(func() {
+		s.info.State = StateFailed
+		s.failed = true
+	})
+}
+
+// SetDone marks the unit as succeeded if err is nil, otherwise as failed
+func (s *Status) SetDone(err error) {
+	if err == nil {
+		s.SetReady(StateSucceeded)
+	} else {
+		s.SetFailed()
+	}
+}
+
+// Wait waits for the unit to be ready or failed, if strict is true, an error is returned if the unit failed
+func (s *Status) Wait(ctx context.Context, strict bool) (err error) {
+	if s == nil {
+		return
+	}
+
+	for {
+		s.mu.Lock()
+		ready, failed, changed := s.info.Ready, s.failed, s.changed
+		s.mu.Unlock()
+
+		if ready {
+			return
+		}
+
+		if failed {
+			if strict {
+				err = ErrUnitFailed
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case <-changed:
+		}
+	}
+}
+
+// Registry holds status of all units by name
+type Registry struct {
+	mu    sync.Mutex
+	units map[string]*Status
+}
+
+// NewRegistry creates a new Registry
+func NewRegistry() *Registry {
+	return &Registry{units: map[string]*Status{}}
+}
+
+// Status returns the status of the unit with the given name, it will be created if not existed
+func (r *Registry) Status(name string) *Status {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.units[name]
+	if !ok {
+		s = newStatus()
+		r.units[name] = s
+	}
+	return s
+}
@@ -0,0 +1,95 @@
+package mrunners
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yankeguo/minit/internal/mexec"
+)
+
+func TestStatusWait(t *testing.T) {
+	reg := NewRegistry()
+
+	s := reg.Status("a")
+	require.Equal(t, StatePending, s.State())
+	require.Same(t, s, reg.Status("a"))
+
+	go func() {
+		time.Sleep(time.Millisecond * 100)
+		s.SetState(StateRunning)
+		time.Sleep(time.Millisecond * 100)
+		s.SetReady(StateSucceeded)
+	}()
+
+	start := time.Now()
+	require.NoError(t, s.Wait(context.Background(), true))
+	require.True(t, time.Since(start) >= time.Millisecond*200)
+	require.Equal(t, StateSucceeded, s.State())
+
+	s = reg.Status("b")
+	s.SetFailed()
+	require.NoError(t, s.Wait(context.Background(), false))
+	require.ErrorIs(t, s.Wait(context.Background(), true), ErrUnitFailed)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	require.ErrorIs(t, reg.Status("c").Wait(ctx, false), context.DeadlineExceeded)
+
+	var nilRegistry *Registry
+	require.Nil(t, nilRegistry.Status("a"))
+	require.NoError(t, nilRegistry.Status("a").Wait(context.Background(), true))
+}
+
+func TestStatusWaitRestarted(t *testing.T) {
+	s := newStatus()
+	s.SetFailed()
+	require.ErrorIs(t, s.Wait(context.Background(), true), ErrUnitFailed)
+
+	// restarted by 'minit ctl restart' or reloading
+	s.SetState(StateRunning)
+	s.SetStarted()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	require.ErrorIs(t, s.Wait(ctx, true), context.DeadlineExceeded)
+
+	s.SetReady(StateRunning)
+	require.NoError(t, s.Wait(context.Background(), true))
+
+	s.SetFailed()
+	s.SetReady(StateSucceeded)
+	s.SetUnready()
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	require.ErrorIs(t, s.Wait(ctx, true), context.DeadlineExceeded)
+}
+
+func TestStatusInfo(t *testing.T) {
+	s := newStatus()
+	require.Nil(t, s.Info().ExitCode)
+	require.True(t, s.Info().StartedAt.IsZero())
+
+	s.SetStarted()
+	s.SetResult(mexec.ExecuteResult{Started: true, ExitCode: -1, Signal: syscall.SIGKILL, OOMKilled: true, MaxRSS: 1024})
+	s.AddRestart(RestartReasonFailed)
+	s.AddRestart(RestartReasonOOMKilled)
+
+	next := time.Now().Add(time.Minute)
+	s.SetCron(time.Time{}, next)
+
+	info := s.Info()
+	require.False(t, info.StartedAt.IsZero())
+	require.Equal(t, -1, *info.ExitCode)
+	require.Equal(t, int64(1024), info.LastResult.MaxRSS)
+	require.Equal(t, 2, info.Restarts)
+	require.Equal(t, RestartReasonOOMKilled, info.RestartReason)
+	require.Equal(t, 1, info.OOMKills)
+	require.Equal(t, next, info.CronNext)
+
+	var nilStatus *Status
+	require.Equal(t, StatePending, nilStatus.Info().State)
+}
@@ -0,0 +1,483 @@
+package mrunners
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/yankeguo/minit/internal/mexec"
+	"github.com/yankeguo/minit/internal/mlog"
+	"github.com/yankeguo/minit/internal/munit"
+)
+
+const (
+	adoptedPollInterval = time.Millisecond * 100
+
+	// killGracePeriod is how long forced shutdown waits for runners after killing all processes
+	killGracePeriod = time.Second * 3
+)
+
+var (
+	ErrUnitNotFound = errors.New("unit not found")
+	ErrShuttingDown = errors.New("minit is shutting down")
+)
+
+// UnitInfo is the runtime information of a unit managed by Supervisor
+type UnitInfo struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+
+	StatusInfo
+
+	Running bool  `json:"running"` // runner of the unit is still active
+	PIDs    []int `json:"pids"`
+}
+
+// supervised is a runner started by Supervisor
+type supervised struct {
+	runner Runner
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (e *supervised) finished() bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Supervisor runs runners, controls them by unit name, and stops them in order on shutdown
+type Supervisor struct {
+	exec   mexec.Manager
+	logger mlog.ProcLogger
+
+	mu      sync.Mutex
+	entries []*supervised // in start order
+	closed  bool
+
+	chErr chan error
+}
+
+// NewSupervisor creates a new Supervisor
+func NewSupervisor(exec mexec.Manager, logger mlog.ProcLogger) *Supervisor {
+	return &Supervisor{
+		exec:   exec,
+		logger: logger,
+		chErr:  make(chan error, 1),
+	}
+}
+
+// Err returns a channel receiving the first error returned by runners, e.g. failure of a critical unit
+func (s *Supervisor) Err() <-chan error {
+	return s.chErr
+}
+
+// ReportError reports an error of a runner running in background, only the first error is kept
+func (s *Supervisor) ReportError(err error) {
+	select {
+	case s.chErr <- err:
+	default:
+	}
+}
+
+// launch creates an entry for the runner, replacing the previous entry of the same unit, and returns the context
+// the runner should use, caller must hold s.mu
+func (s *Supervisor) launch(runner Runner) (e *supervised, ctx context.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	e = &supervised{
+		runner: runner,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	for i, prev := range s.entries {
+		if prev.runner.Unit.Name == runner.Unit.Name {
+			s.entries[i] = e
+			return
+		}
+	}
+
+	s.entries = append(s.entries, e)
+	return
+}
+
+// Run runs the runner in foreground, used for short runners
+func (s *Supervisor) Run(runner Runner) error {
+	return s.run(context.Background(), runner)
+}
+
+// run is like Run, but the runner is cancelled once ctx is done
+func (s *Supervisor) run(ctx context.Context, runner Runner) error {
+	s.mu.Lock()
+	e, rctx := s.launch(runner)
+	s.mu.Unlock()
+
+	defer close(e.done)
+	defer context.AfterFunc(ctx, e.cancel)()
+
+	return runner.Action.Do(rctx)
+}
+
+// Start runs the runner in background, with its own context
+func (s *Supervisor) Start(runner Runner) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.start(runner)
+}
+
+// start runs the runner in background, caller must hold s.mu
+func (s *Supervisor) start(runner Runner) {
+	e, ctx := s.launch(runner)
+
+	go func() {
+		defer close(e.done)
+		// errors of units stopped on purpose are ignored
+		if err := runner.Action.Do(ctx); err != nil && ctx.Err() == nil {
+			s.ReportError(err)
+		}
+	}()
+}
+
+func (s *Supervisor) find(name string) *supervised {
+	for _, e := range s.entries {
+		if e.runner.Unit.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// Units returns runtime information of all units, in start order
+func (s *Supervisor) Units() (units []UnitInfo) {
+	s.mu.Lock()
+	entries := append([]*supervised{}, s.entries...)
+	s.mu.Unlock()
+
+	for _, e := range entries {
+		units = append(units, UnitInfo{
+			Name:       e.runner.Unit.Name,
+			Kind:       e.runner.Unit.Kind,
+			StatusInfo: e.runner.Status.Info(),
+			Running:    !e.finished(),
+			PIDs:       s.exec.PIDs(e.runner.Unit.ID()),
+		})
+	}
+	return
+}
+
+// StartUnit starts a unit again in background, the unit must not be running
+func (s *Supervisor) StartUnit(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrShuttingDown
+	}
+
+	e := s.find(name)
+	if e == nil {
+		return fmt.Errorf("%w: %s", ErrUnitNotFound, name)
+	}
+	if !e.finished() || len(s.exec.PIDs(e.runner.Unit.ID())) != 0 {
+		return fmt.Errorf("unit '%s' is already running", name)
+	}
+
+	s.logger.Print("starting " + e.runner.Unit.ID())
+
+	s.start(e.runner)
+	return nil
+}
+
+// StopUnit stops a unit and waits for it, processes receive their stop signal, or SIGTERM if not set
+func (s *Supervisor) StopUnit(ctx context.Context, name string) error {
+	s.mu.Lock()
+	e := s.find(name)
+	s.mu.Unlock()
+
+	if e == nil {
+		return fmt.Errorf("%w: %s", ErrUnitNotFound, name)
+	}
+
+	s.logger.Print("stopping " + e.runner.Unit.ID())
+
+	s.stop(ctx, e, syscall.SIGTERM)
+
+	return ctx.Err()
+}
+
+// RestartUnit stops and starts a unit
+func (s *Supervisor) RestartUnit(ctx context.Context, name string) error {
+	if err := s.StopUnit(ctx, name); err != nil {
+		return err
+	}
+	return s.StartUnit(name)
+}
+
+// SignalUnit sends a signal to processes of a unit
+func (s *Supervisor) SignalUnit(name string, sig os.Signal) error {
+	s.mu.Lock()
+	e := s.find(name)
+	s.mu.Unlock()
+
+	if e == nil {
+		return fmt.Errorf("%w: %s", ErrUnitNotFound, name)
+	}
+
+	s.logger.Printf("sending %s to %s", sig, e.runner.Unit.ID())
+
+	s.exec.SignalName(e.runner.Unit.ID(), sig)
+	return nil
+}
+
+// ForwardSignal sends the signal to processes of units listing it in 'forward_signals'
+func (s *Supervisor) ForwardSignal(sig os.Signal) {
+	s.mu.Lock()
+	entries := append([]*supervised{}, s.entries...)
+	s.mu.Unlock()
+
+	for _, e := range entries {
+		if !e.runner.Unit.ForwardsSignal(sig) {
+			continue
+		}
+
+		s.logger.Printf("forwarding %s to %s", sig, e.runner.Unit.ID())
+
+		s.exec.SignalName(e.runner.Unit.ID(), sig)
+	}
+}
+
+// Reload applies a new set of runners, removed units are stopped, new units are started, units with changed
+// definitions are restarted. Unchanged 'render' and 'once' units are only executed again with 'rerun_on_reload',
+// changed ones are executed again only with it too. Like startup, short runners are executed before long runners.
+// Once ctx is done, running short runners are cancelled and ctx.Err() is returned.
+func (s *Supervisor) Reload(ctx context.Context, runners []Runner) (err error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrShuttingDown
+	}
+	entries := append([]*supervised{}, s.entries...)
+	s.mu.Unlock()
+
+	current := map[string]*supervised{}
+	for _, e := range entries {
+		current[e.runner.Unit.Name] = e
+	}
+
+	wanted := map[string]struct{}{}
+	for _, runner := range runners {
+		wanted[runner.Unit.Name] = struct{}{}
+	}
+
+	// stop removed units in reverse start order
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if _, ok := wanted[e.runner.Unit.Name]; ok {
+			continue
+		}
+
+		s.logger.Print("unit removed: " + e.runner.Unit.ID())
+
+		s.stop(ctx, e, syscall.SIGTERM)
+
+		s.mu.Lock()
+		s.entries = slices.DeleteFunc(s.entries, func(item *supervised) bool { return item == e })
+		s.mu.Unlock()
+	}
+
+	// stop changed units
+	changed := map[string]bool{}
+
+	for _, runner := range runners {
+		e, ok := current[runner.Unit.Name]
+		if !ok {
+			s.logger.Print("unit added: " + runner.Unit.ID())
+			changed[runner.Unit.Name] = true
+			continue
+		}
+		if reflect.DeepEqual(e.runner.Unit, runner.Unit) {
+			continue
+		}
+
+		s.logger.Print("unit changed: " + runner.Unit.ID())
+		changed[runner.Unit.Name] = true
+
+		if !e.finished() || len(s.exec.PIDs(e.runner.Unit.ID())) != 0 {
+			s.logger.Print("stopping " + e.runner.Unit.ID())
+			s.stop(ctx, e, syscall.SIGTERM)
+		}
+	}
+
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	// execute short runners, they are cancelled once ctx is done, e.g. shutting down while reloading
+	for _, runner := range runners {
+		if runner.Long {
+			continue
+		}
+
+		_, existed := current[runner.Unit.Name]
+
+		if !existed || runner.Unit.RerunOnReload {
+			if err = ctx.Err(); err != nil {
+				return
+			}
+			if err = s.run(ctx, runner); err != nil {
+				return
+			}
+			continue
+		}
+
+		if changed[runner.Unit.Name] {
+			// keep the new definition for later 'start', without executing it
+			s.mu.Lock()
+			e, _ := s.launch(runner)
+			s.mu.Unlock()
+			e.cancel()
+			close(e.done)
+		}
+	}
+
+	// start long runners
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrShuttingDown
+	}
+
+	for _, runner := range runners {
+		if runner.Long && changed[runner.Unit.Name] {
+			s.start(runner)
+		}
+	}
+
+	return
+}
+
+// stop stops processes of the runner and cancels it, waits for both or ctx done
+func (s *Supervisor) stop(ctx context.Context, e *supervised, sig os.Signal) {
+	// processes are stopped before cancelling, so that they receive sig instead of SIGTERM from cancellation
+	stopped := s.exec.Stop(e.runner.Unit.ID(), sig)
+
+	e.cancel()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+	}
+
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+	}
+}
+
+// Shutdown stops runners in reverse start order, 'cron' units first, then other long running units, and finally all
+// remaining processes like non-blocking 'once' units and orphaned descendants adopted by minit. Processes receive
+// their stop signal, or sig if not set.
+// If ctx is done before everything stopped, all processes will be killed immediately, and runners still not stopped
+// after a short grace period are left behind.
+func (s *Supervisor) Shutdown(ctx context.Context, sig os.Signal) {
+	s.mu.Lock()
+	s.closed = true
+	entries := append([]*supervised{}, s.entries...)
+	s.mu.Unlock()
+
+	var ordered []*supervised
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].runner.Unit.Kind == munit.KindCron {
+			ordered = append(ordered, entries[i])
+		}
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].runner.Unit.Kind != munit.KindCron {
+			ordered = append(ordered, entries[i])
+		}
+	}
+
+	for _, e := range ordered {
+		if ctx.Err() != nil {
+			break
+		}
+
+		// finished runners, e.g. 'render' units, have nothing to stop
+		if e.finished() {
+			continue
+		}
+
+		s.logger.Print("stopping " + e.runner.Unit.ID())
+
+		s.stop(ctx, e, sig)
+	}
+
+	if ctx.Err() == nil {
+		select {
+		case <-s.exec.StopAll(sig):
+		case <-ctx.Done():
+		}
+	}
+
+	if ctx.Err() == nil {
+		s.stopAdopted(ctx, sig)
+	}
+
+	if ctx.Err() != nil {
+		s.logger.Error("shutdown not finished in time or forced, killing all processes")
+
+		for _, e := range entries {
+			e.cancel()
+		}
+
+		s.exec.Signal(syscall.SIGKILL)
+		mexec.SignalAdopted(syscall.SIGKILL)
+
+		// runners not aware of ctx, e.g. blocked in a non-cancellable action, must not keep minit from exiting
+		grace := time.NewTimer(killGracePeriod)
+		defer grace.Stop()
+
+		for _, e := range entries {
+			select {
+			case <-e.done:
+			case <-grace.C:
+				s.logger.Error("runners not stopped after killing all processes, giving up")
+				return
+			}
+		}
+	}
+}
+
+// stopAdopted sends sig to orphaned descendants adopted by minit, and waits for them to exit or ctx done
+func (s *Supervisor) stopAdopted(ctx context.Context, sig os.Signal) {
+	pids := mexec.SignalAdopted(sig)
+	if len(pids) == 0 {
+		return
+	}
+
+	s.logger.Printf("stopping %d adopted processes: %v", len(pids), pids)
+
+	tk := time.NewTicker(adoptedPollInterval)
+	defer tk.Stop()
+
+	for len(mexec.AdoptedPIDs()) != 0 {
+		select {
+		case <-tk.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
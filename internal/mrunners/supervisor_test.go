@@ -0,0 +1,351 @@
+package mrunners
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yankeguo/minit/internal/mexec"
+	"github.com/yankeguo/minit/internal/mlog"
+	"github.com/yankeguo/minit/internal/munit"
+	"github.com/yankeguo/rg"
+)
+
+// syncBuffer is a bytes.Buffer safe for loggers writing from many goroutines while the test reads it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestSupervisorShutdown(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	buf := &syncBuffer{}
+
+	logger := rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{
+		ConsoleOut: buf,
+		ConsoleErr: buf,
+	}))
+
+	sup := NewSupervisor(exem, logger)
+
+	for _, unit := range []munit.Unit{
+		{Kind: munit.KindDaemon, Name: "a", Command: []string{"sleep", "100"}},
+		{Kind: munit.KindCron, Name: "b", Cron: "@every 1h", Command: []string{"true"}},
+		{Kind: munit.KindDaemon, Name: "c", Command: []string{"sleep", "100"}},
+	} {
+		sup.Start(rg.Must(Create(RunnerOptions{
+			Unit:   unit,
+			Exec:   exem,
+			Logger: logger,
+		})))
+	}
+
+	time.Sleep(time.Millisecond * 500)
+
+	t1 := time.Now()
+
+	sup.Shutdown(context.Background(), syscall.SIGTERM)
+
+	require.True(t, time.Since(t1) < time.Second)
+
+	output := buf.String()
+	idxB := strings.Index(output, "stopping cron/b")
+	idxC := strings.Index(output, "stopping daemon/c")
+	idxA := strings.Index(output, "stopping daemon/a")
+	require.True(t, idxB >= 0 && idxC > idxB && idxA > idxC)
+}
+
+func TestSupervisorShutdownForce(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	buf := &syncBuffer{}
+
+	logger := rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{
+		ConsoleOut: buf,
+		ConsoleErr: buf,
+	}))
+
+	sup := NewSupervisor(exem, logger)
+
+	sup.Start(rg.Must(Create(RunnerOptions{
+		Unit: munit.Unit{
+			Kind:        munit.KindDaemon,
+			Name:        "stubborn",
+			Shell:       "/bin/bash",
+			Command:     []string{"trap '' TERM", "while true; do sleep 0.1; done"},
+			StopTimeout: time.Minute,
+		},
+		Exec:   exem,
+		Logger: logger,
+	})))
+
+	time.Sleep(time.Millisecond * 500)
+
+	t1 := time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
+	defer cancel()
+
+	sup.Shutdown(ctx, syscall.SIGTERM)
+
+	require.True(t, time.Since(t1) < time.Second*2)
+	require.Contains(t, buf.String(), "killing all processes")
+}
+
+// stuckAction ignores ctx, like an action blocked in a non-cancellable call
+type stuckAction struct{}
+
+func (stuckAction) Do(ctx context.Context) error {
+	select {}
+}
+
+func TestSupervisorShutdownStuck(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	buf := &syncBuffer{}
+
+	logger := rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{
+		ConsoleOut: buf,
+		ConsoleErr: buf,
+	}))
+
+	sup := NewSupervisor(exem, logger)
+
+	sup.Start(Runner{
+		Unit:   munit.Unit{Kind: munit.KindDaemon, Name: "stuck"},
+		Long:   true,
+		Action: stuckAction{},
+	})
+
+	t1 := time.Now()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	sup.Shutdown(ctx, syscall.SIGTERM)
+
+	require.True(t, time.Since(t1) < killGracePeriod+time.Second)
+	require.Contains(t, buf.String(), "giving up")
+}
+
+func TestSupervisorErr(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	logger := rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{}))
+
+	sup := NewSupervisor(exem, logger)
+
+	sup.Start(rg.Must(Create(RunnerOptions{
+		Unit: munit.Unit{
+			Kind:     munit.KindDaemon,
+			Name:     "broken",
+			Critical: true,
+			Command:  []string{"false"},
+		},
+		Exec:   exem,
+		Logger: logger,
+	})))
+
+	select {
+	case err := <-sup.Err():
+		require.Error(t, err)
+	case <-time.After(time.Second * 3):
+		t.Fatal("no error received")
+	}
+
+	sup.Shutdown(context.Background(), syscall.SIGTERM)
+}
+
+func TestSupervisorReload(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	buf := &syncBuffer{}
+
+	logger := rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{
+		ConsoleOut: buf,
+		ConsoleErr: buf,
+	}))
+
+	registry := NewRegistry()
+
+	create := func(units ...munit.Unit) (runners []Runner) {
+		for _, unit := range units {
+			runners = append(runners, rg.Must(Create(RunnerOptions{
+				Unit:     unit,
+				Exec:     exem,
+				Logger:   logger,
+				Registry: registry,
+			})))
+		}
+		return
+	}
+
+	var (
+		unitPrepare  = munit.Unit{Kind: munit.KindOnce, Name: "prepare", Command: []string{"echo", "prepared"}, RerunOnReload: true}
+		unitOnce     = munit.Unit{Kind: munit.KindOnce, Name: "once", Command: []string{"echo", "once"}}
+		unitKept     = munit.Unit{Kind: munit.KindDaemon, Name: "kept", Command: []string{"sleep", "100"}}
+		unitChanged  = munit.Unit{Kind: munit.KindDaemon, Name: "changed", Command: []string{"sleep", "100"}}
+		unitRemoved  = munit.Unit{Kind: munit.KindDaemon, Name: "removed", Command: []string{"sleep", "100"}}
+		unitAdded    = munit.Unit{Kind: munit.KindDaemon, Name: "added", Command: []string{"sleep", "100"}}
+		unitChanged2 = munit.Unit{Kind: munit.KindDaemon, Name: "changed", Command: []string{"sleep", "200"}}
+	)
+
+	sup := NewSupervisor(exem, logger)
+	defer sup.Shutdown(context.Background(), syscall.SIGTERM)
+
+	for _, runner := range create(unitPrepare, unitOnce) {
+		require.NoError(t, sup.Run(runner))
+	}
+	for _, runner := range create(unitKept, unitChanged, unitRemoved) {
+		sup.Start(runner)
+	}
+
+	time.Sleep(time.Millisecond * 500)
+
+	pidKept := exem.PIDs(unitKept.ID())
+	pidChanged := exem.PIDs(unitChanged.ID())
+
+	require.NoError(t, sup.Reload(context.Background(), create(unitPrepare, unitOnce, unitKept, unitChanged2, unitAdded)))
+
+	time.Sleep(time.Millisecond * 500)
+
+	require.Equal(t, pidKept, exem.PIDs(unitKept.ID()))
+	require.NotEqual(t, pidChanged, exem.PIDs(unitChanged.ID()))
+	require.Len(t, exem.PIDs(unitChanged.ID()), 1)
+	require.Empty(t, exem.PIDs(unitRemoved.ID()))
+	require.Len(t, exem.PIDs(unitAdded.ID()), 1)
+
+	var names []string
+	for _, unit := range sup.Units() {
+		names = append(names, unit.Name)
+	}
+	require.Equal(t, []string{"prepare", "once", "kept", "changed", "added"}, names)
+
+	output := buf.String()
+	require.Equal(t, 2, strings.Count(output, "prepared\n"))
+	require.Equal(t, 1, strings.Count(output, "once\n"))
+	require.Contains(t, output, "unit removed: daemon/removed")
+	require.Contains(t, output, "unit changed: daemon/changed")
+	require.Contains(t, output, "unit added: daemon/added")
+	require.NotContains(t, output, "unit changed: daemon/kept")
+}
+
+func TestSupervisorReloadCancel(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	logger := rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{}))
+
+	create := func(unit munit.Unit) Runner {
+		return rg.Must(Create(RunnerOptions{
+			Unit:     unit,
+			Exec:     exem,
+			Logger:   logger,
+			Registry: NewRegistry(),
+		}))
+	}
+
+	sup := NewSupervisor(exem, logger)
+
+	ctx, ctxCancel := context.WithCancel(context.Background())
+
+	chErr := make(chan error, 1)
+	go func() {
+		chErr <- sup.Reload(ctx, []Runner{
+			create(munit.Unit{Kind: munit.KindOnce, Name: "hang", Command: []string{"sleep", "100"}}),
+			create(munit.Unit{Kind: munit.KindOnce, Name: "next", Command: []string{"echo", "next"}}),
+		})
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(exem.PIDs("once/hang")) == 1
+	}, time.Second*3, time.Millisecond*50)
+
+	t1 := time.Now()
+
+	// shutting down while reloading
+	ctxCancel()
+
+	select {
+	case err := <-chErr:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second * 5):
+		t.Fatal("reload was not cancelled")
+	}
+	require.True(t, time.Since(t1) < time.Second*2)
+	require.Empty(t, exem.PIDs("once/hang"))
+
+	var names []string
+	for _, unit := range sup.Units() {
+		names = append(names, unit.Name)
+	}
+	require.Equal(t, []string{"hang"}, names)
+
+	sup.Shutdown(context.Background(), syscall.SIGTERM)
+}
+
+func TestSupervisorForwardSignal(t *testing.T) {
+	exem := mexec.NewManager(mexec.ManagerOptions{})
+
+	buf := &syncBuffer{}
+
+	logger := rg.Must(mlog.NewProcLogger(mlog.ProcLoggerOptions{
+		ConsoleOut: buf,
+		ConsoleErr: buf,
+	}))
+
+	sup := NewSupervisor(exem, logger)
+	defer sup.Shutdown(context.Background(), syscall.SIGTERM)
+
+	for _, unit := range []munit.Unit{
+		{
+			Kind:           munit.KindDaemon,
+			Name:           "a",
+			Shell:          "/bin/bash",
+			ForwardSignals: []string{"SIGHUP"},
+			Command:        []string{"trap 'echo a got hup' HUP", "trap 'echo a got usr1' USR1", "while true; do sleep 0.1; done"},
+		},
+		{
+			Kind:    munit.KindDaemon,
+			Name:    "b",
+			Shell:   "/bin/bash",
+			Command: []string{"trap 'echo b got hup' HUP", "while true; do sleep 0.1; done"},
+		},
+	} {
+		sup.Start(rg.Must(Create(RunnerOptions{
+			Unit:   unit,
+			Exec:   exem,
+			Logger: logger,
+		})))
+	}
+
+	time.Sleep(time.Millisecond * 500)
+
+	sup.ForwardSignal(syscall.SIGHUP)
+	sup.ForwardSignal(syscall.SIGUSR1)
+
+	time.Sleep(time.Millisecond * 500)
+
+	output := buf.String()
+	require.Contains(t, output, "forwarding hangup to daemon/a")
+	require.Contains(t, output, "a got hup")
+	require.NotContains(t, output, "a got usr1")
+	require.NotContains(t, output, "b got hup")
+}
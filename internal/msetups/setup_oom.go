@@ -0,0 +1,39 @@
+package msetups
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/yankeguo/minit/internal/mexec"
+	"github.com/yankeguo/minit/internal/mlog"
+)
+
+func init() {
+	Register(35, setupOOMScoreAdj)
+}
+
+// setupOOMScoreAdj lowers oom_score_adj of minit itself, so the kernel kills a unit rather than minit, units are
+// reset to the previous value unless 'oom_score_adj' is set
+func setupOOMScoreAdj(logger mlog.ProcLogger) (err error) {
+	val := strings.TrimSpace(os.Getenv("MINIT_OOM_SCORE_ADJ"))
+	if val == "" {
+		return
+	}
+
+	var v int
+	if v, err = strconv.Atoi(val); err != nil || v < -1000 || v > 1000 {
+		err = fmt.Errorf("invalid environment variable MINIT_OOM_SCORE_ADJ=%s: must be within -1000 to 1000", val)
+		return
+	}
+
+	logger.Printf("setting oom_score_adj=%d", v)
+
+	if err = mexec.SetOOMScoreAdj(v); err != nil {
+		err = fmt.Errorf("failed setting oom_score_adj=%d: %s", v, err.Error())
+		return
+	}
+
+	return
+}
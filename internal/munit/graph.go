@@ -0,0 +1,139 @@
+package munit
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// isStartup returns true if the unit is executed sequentially at startup, before any long running unit
+func isStartup(unit Unit) bool {
+	switch unit.Kind {
+	case KindRender:
+		return true
+	case KindOnce:
+		return unit.Blocking == nil || *unit.Blocking
+	default:
+		return false
+	}
+}
+
+// resolveDependencies expands 'after' and 'requires' of units to names of replicated units, rejects unknown
+// names and cycles, and sorts units so that every unit comes after its dependencies
+//
+// replicas maps the original unit name to names of replicated units, disabled contains names of skipped units
+func resolveDependencies(units []Unit, replicas map[string][]string, disabled map[string]struct{}) (sorted []Unit, err error) {
+	index := map[string]int{}
+	for i, unit := range units {
+		index[unit.Name] = i
+	}
+
+	expand := func(unit Unit, deps []string, strict bool) (out []string, err error) {
+		for _, dep := range deps {
+			dep = strings.TrimSpace(dep)
+			if dep == "" {
+				continue
+			}
+
+			names, ok := replicas[dep]
+			if !ok {
+				if _, ok = index[dep]; ok {
+					names = []string{dep}
+				}
+			}
+
+			if !ok {
+				if _, found := disabled[dep]; found {
+					if strict {
+						err = fmt.Errorf("unit '%s' requires disabled unit '%s'", unit.Name, dep)
+						return
+					}
+					continue
+				}
+				err = fmt.Errorf("unit '%s' depends on unknown unit '%s'", unit.Name, dep)
+				return
+			}
+
+			for _, name := range names {
+				if name == unit.Name {
+					err = fmt.Errorf("unit '%s' depends on itself", unit.Name)
+					return
+				}
+
+				if !slices.Contains(out, name) {
+					out = append(out, name)
+				}
+			}
+		}
+		return
+	}
+
+	for i := range units {
+		if units[i].After, err = expand(units[i], units[i].After, false); err != nil {
+			return
+		}
+		if units[i].Requires, err = expand(units[i], units[i].Requires, true); err != nil {
+			return
+		}
+	}
+
+	// stable topological sort, always pick the first unit with all dependencies satisfied
+	var (
+		done    = map[string]struct{}{}
+		pending = make([]Unit, len(units))
+	)
+	copy(pending, units)
+
+	for len(pending) > 0 {
+		picked := -1
+
+		for i, unit := range pending {
+			satisfied := true
+			for _, dep := range append(append([]string{}, unit.After...), unit.Requires...) {
+				if _, ok := done[dep]; !ok {
+					satisfied = false
+					break
+				}
+			}
+			if satisfied {
+				picked = i
+				break
+			}
+		}
+
+		if picked < 0 {
+			var names []string
+			for _, unit := range pending {
+				names = append(names, unit.Name)
+			}
+			sort.Strings(names)
+			err = fmt.Errorf("dependency cycle detected among units: %s", strings.Join(names, ", "))
+			return
+		}
+
+		done[pending[picked].Name] = struct{}{}
+		sorted = append(sorted, pending[picked])
+		pending = append(pending[:picked], pending[picked+1:]...)
+	}
+
+	// startup units must not wait for long running units, directly or indirectly, or minit will deadlock
+	waitsLong := map[string]bool{}
+
+	for _, unit := range sorted {
+		waitsLong[unit.Name] = unit.Kind == KindDaemon || unit.Kind == KindCron
+
+		for _, dep := range append(append([]string{}, unit.After...), unit.Requires...) {
+			if !waitsLong[dep] {
+				continue
+			}
+			if isStartup(unit) {
+				err = fmt.Errorf("unit '%s' cannot depend on unit '%s': %s units run before daemon and cron units are started", unit.Name, dep, unit.Kind)
+				return
+			}
+			waitsLong[unit.Name] = true
+		}
+	}
+
+	return
+}
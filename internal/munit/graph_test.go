@@ -0,0 +1,110 @@
+package munit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveDependencies(t *testing.T) {
+	nonBlocking := false
+
+	units := []Unit{
+		{Name: "web", Kind: KindDaemon, After: []string{"worker"}, Requires: []string{"migrate"}},
+		{Name: "worker-1", Kind: KindDaemon},
+		{Name: "worker-2", Kind: KindDaemon},
+		{Name: "migrate", Kind: KindOnce, After: []string{"config"}},
+		{Name: "config", Kind: KindRender},
+		{Name: "warmup", Kind: KindOnce, Blocking: &nonBlocking, After: []string{"web", "legacy"}},
+	}
+
+	replicas := map[string][]string{
+		"web":     {"web"},
+		"worker":  {"worker-1", "worker-2"},
+		"migrate": {"migrate"},
+		"config":  {"config"},
+		"warmup":  {"warmup"},
+	}
+
+	sorted, err := resolveDependencies(units, replicas, map[string]struct{}{"legacy": {}})
+	require.NoError(t, err)
+
+	var names []string
+	for _, unit := range sorted {
+		names = append(names, unit.Name)
+	}
+	require.Equal(t, []string{"worker-1", "worker-2", "config", "migrate", "web", "warmup"}, names)
+	require.Equal(t, []string{"worker-1", "worker-2"}, sorted[4].After)
+	require.Equal(t, []string{"migrate"}, sorted[4].Requires)
+	require.Equal(t, []string{"web"}, sorted[5].After)
+
+	_, err = resolveDependencies([]Unit{
+		{Name: "a", Kind: KindDaemon, Requires: []string{"legacy"}},
+	}, map[string][]string{"a": {"a"}}, map[string]struct{}{"legacy": {}})
+	require.ErrorContains(t, err, "requires disabled unit 'legacy'")
+
+	_, err = resolveDependencies([]Unit{
+		{Name: "a", Kind: KindDaemon, After: []string{"b"}},
+	}, map[string][]string{"a": {"a"}}, nil)
+	require.ErrorContains(t, err, "unknown unit 'b'")
+
+	_, err = resolveDependencies([]Unit{
+		{Name: "a", Kind: KindDaemon, After: []string{"a"}},
+	}, map[string][]string{"a": {"a"}}, nil)
+	require.ErrorContains(t, err, "depends on itself")
+
+	_, err = resolveDependencies([]Unit{
+		{Name: "a", Kind: KindDaemon, After: []string{"b"}},
+		{Name: "b", Kind: KindDaemon, Requires: []string{"c"}},
+		{Name: "c", Kind: KindDaemon, After: []string{"a"}},
+		{Name: "d", Kind: KindDaemon},
+	}, map[string][]string{"a": {"a"}, "b": {"b"}, "c": {"c"}, "d": {"d"}}, nil)
+	require.ErrorContains(t, err, "dependency cycle detected among units: a, b, c")
+
+	_, err = resolveDependencies([]Unit{
+		{Name: "a", Kind: KindDaemon},
+		{Name: "b", Kind: KindOnce, Blocking: &nonBlocking, After: []string{"a"}},
+		{Name: "c", Kind: KindOnce, After: []string{"b"}},
+	}, map[string][]string{"a": {"a"}, "b": {"b"}, "c": {"c"}}, nil)
+	require.ErrorContains(t, err, "unit 'c' cannot depend on unit 'b'")
+}
+
+func TestLoadDependencies(t *testing.T) {
+	_, _, err := Load(LoadOptions{
+		Env: map[string]string{
+			"MINIT_UNIT_A_COMMAND":  "sleep 10",
+			"MINIT_UNIT_A_AFTER":    "env-b",
+			"MINIT_UNIT_B_COMMAND":  "sleep 10",
+			"MINIT_UNIT_B_COUNT":    "2",
+			"MINIT_UNIT_B_REQUIRES": "env-c",
+			"MINIT_UNIT_C_COMMAND":  "true",
+			"MINIT_UNIT_C_KIND":     "once",
+		},
+	})
+	require.NoError(t, err)
+
+	units, _, err := Load(LoadOptions{
+		Env: map[string]string{
+			"MINIT_UNIT_A_COMMAND": "sleep 10",
+			"MINIT_UNIT_A_AFTER":   "env-b",
+			"MINIT_UNIT_B_COMMAND": "sleep 10",
+			"MINIT_UNIT_B_COUNT":   "2",
+		},
+	})
+	require.NoError(t, err)
+	for _, unit := range units {
+		if unit.Name == "env-a" {
+			require.Equal(t, []string{"env-b-1", "env-b-2"}, unit.After)
+		}
+	}
+
+	_, _, err = Load(LoadOptions{
+		Env: map[string]string{
+			"MINIT_UNIT_A_COMMAND": "sleep 10",
+			"MINIT_UNIT_A_AFTER":   "env-b",
+			"MINIT_UNIT_B_COMMAND": "sleep 10",
+			"MINIT_UNIT_B_AFTER":   "env-a",
+		},
+	})
+	require.ErrorContains(t, err, "dependency cycle detected")
+}
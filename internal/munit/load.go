@@ -74,17 +74,15 @@ func Load(opts LoadOptions) (output []Unit, skipped []Unit, err error) {
 
 	names[NameMinit] = struct{}{}
 
+	// names of replicated units, names of skipped units
+	var (
+		replicas = map[string][]string{}
+		disabled = map[string]struct{}{}
+	)
+
 	// whitelist / blacklist, replicas
 	for _, unit := range units {
-		// check unit kind
-		if _, ok := knownUnitKind[unit.Kind]; !ok {
-			err = fmt.Errorf("invalid unit kind '%s' for unit '%s': must be one of: render, once, daemon, cron", unit.Kind, unit.Name)
-			return
-		}
-
-		// check unit name
-		if !regexpName.MatchString(unit.Name) {
-			err = fmt.Errorf("invalid unit name '%s': name must start with a letter, contain only alphanumeric characters, hyphens, or underscores, and end with an alphanumeric character", unit.Name)
+		if err = unit.Validate(); err != nil {
 			return
 		}
 
@@ -104,6 +102,7 @@ func Load(opts LoadOptions) (output []Unit, skipped []Unit, err error) {
 		// skip if needed
 		if !filter.Match(unit) {
 			skipped = append(skipped, unit)
+			disabled[unit.Name] = struct{}{}
 			continue
 		}
 
@@ -124,6 +123,8 @@ func Load(opts LoadOptions) (output []Unit, skipped []Unit, err error) {
 				subUnit.Env["MINIT_UNIT_NAME"] = subUnit.Name
 				subUnit.Env["MINIT_UNIT_SUB_ID"] = strconv.Itoa(i + 1)
 
+				replicas[unit.Name] = append(replicas[unit.Name], subUnit.Name)
+
 				output = append(output, subUnit)
 			}
 		} else {
@@ -132,10 +133,15 @@ func Load(opts LoadOptions) (output []Unit, skipped []Unit, err error) {
 			unit.Env["MINIT_UNIT_NAME"] = unit.Name
 			unit.Env["MINIT_UNIT_SUB_ID"] = "1"
 
+			replicas[unit.Name] = []string{unit.Name}
+
 			output = append(output, unit)
 		}
 	}
 
+	// resolve 'after' and 'requires'
+	output, err = resolveDependencies(output, replicas, disabled)
+
 	return
 }
 
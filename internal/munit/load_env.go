@@ -4,6 +4,7 @@ import (
 	"errors"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/yankeguo/minit/pkg/shellquote"
 )
@@ -87,8 +88,62 @@ func LoadEnvWithInfix(env map[string]string, infix string) (unit Unit, ok bool,
 		}
 
 		unit.Dir = env[EnvPrefixUnit+infix+"_DIR"]
+		unit.Chroot = strings.TrimSpace(env[EnvPrefixUnit+infix+"_CHROOT"])
+		unit.Umask = strings.TrimSpace(env[EnvPrefixUnit+infix+"_UMASK"])
+
+		// mode or owner implies creating the directory
+		createDir, _ := strconv.ParseBool(env[EnvPrefixUnit+infix+"_CREATE_DIR"])
+		createDirMode := strings.TrimSpace(env[EnvPrefixUnit+infix+"_CREATE_DIR_MODE"])
+		createDirOwner := strings.TrimSpace(env[EnvPrefixUnit+infix+"_CREATE_DIR_OWNER"])
+		if createDir || createDirMode != "" || createDirOwner != "" {
+			unit.CreateDir = &CreateDir{Mode: createDirMode, Owner: createDirOwner}
+		}
 		unit.Shell = env[EnvPrefixUnit+infix+"_SHELL"]
 		unit.Charset = env[EnvPrefixUnit+infix+"_CHARSET"]
+		unit.StopSignal = env[EnvPrefixUnit+infix+"_STOP_SIGNAL"]
+		unit.KillMode = env[EnvPrefixUnit+infix+"_KILL_MODE"]
+		unit.ForwardSignals = splitEnvList(env[EnvPrefixUnit+infix+"_FORWARD_SIGNALS"])
+		unit.User = env[EnvPrefixUnit+infix+"_USER"]
+		unit.SupplementaryGroups = splitEnvList(env[EnvPrefixUnit+infix+"_SUPPLEMENTARY_GROUPS"])
+		unit.Capabilities = splitEnvList(env[EnvPrefixUnit+infix+"_CAPABILITIES"])
+		unit.DropCapabilities = splitEnvList(env[EnvPrefixUnit+infix+"_DROP_CAPABILITIES"])
+		unit.NoNewPrivileges, _ = strconv.ParseBool(env[EnvPrefixUnit+infix+"_NO_NEW_PRIVILEGES"])
+
+		if err = parseEnvDuration(env, EnvPrefixUnit+infix+"_STOP_TIMEOUT", &unit.StopTimeout); err != nil {
+			return
+		}
+
+		unit.IONiceClass = strings.TrimSpace(env[EnvPrefixUnit+infix+"_IONICE_CLASS"])
+		unit.CPUAffinity = strings.TrimSpace(env[EnvPrefixUnit+infix+"_CPU_AFFINITY"])
+
+		if err = parseEnvInt(env, EnvPrefixUnit+infix+"_NICE", &unit.Nice); err != nil {
+			return
+		}
+		if err = parseEnvIntPtr(env, EnvPrefixUnit+infix+"_IONICE_LEVEL", &unit.IONiceLevel); err != nil {
+			return
+		}
+		if err = parseEnvIntPtr(env, EnvPrefixUnit+infix+"_OOM_SCORE_ADJ", &unit.OOMScoreAdj); err != nil {
+			return
+		}
+
+		unit.MemoryMax = strings.TrimSpace(env[EnvPrefixUnit+infix+"_MEMORY_MAX"])
+		unit.CPUMax = strings.TrimSpace(env[EnvPrefixUnit+infix+"_CPU_MAX"])
+		unit.PIDsMax = strings.TrimSpace(env[EnvPrefixUnit+infix+"_PIDS_MAX"])
+
+		if err = parseEnvInt(env, EnvPrefixUnit+infix+"_IO_WEIGHT", &unit.IOWeight); err != nil {
+			return
+		}
+
+		for _, item := range strings.Split(env[EnvPrefixUnit+infix+"_RLIMITS"], ";") {
+			item = strings.TrimSpace(item)
+			splits := strings.SplitN(item, "=", 2)
+			if len(splits) == 2 {
+				if unit.RLimits == nil {
+					unit.RLimits = make(map[string]string)
+				}
+				unit.RLimits[strings.TrimSpace(splits[0])] = strings.TrimSpace(splits[1])
+			}
+		}
 
 		for _, item := range strings.Split(env[EnvPrefixUnit+infix+"_ENV"], ";") {
 			item = strings.TrimSpace(item)
@@ -114,6 +169,44 @@ func LoadEnvWithInfix(env map[string]string, infix string) (unit Unit, ok bool,
 		unit.Immediate, _ = strconv.ParseBool(env[EnvPrefixUnit+infix+"_IMMEDIATE"])
 	}
 
+	// timeout
+	if unit.Kind == KindOnce || unit.Kind == KindCron {
+		if err = parseEnvDuration(env, EnvPrefixUnit+infix+"_TIMEOUT", &unit.Timeout); err != nil {
+			return
+		}
+	}
+
+	// pid file, restart policy
+	if unit.Kind == KindDaemon {
+		unit.PIDFile = strings.TrimSpace(env[EnvPrefixUnit+infix+"_PID_FILE"])
+		unit.Restart = strings.TrimSpace(env[EnvPrefixUnit+infix+"_RESTART"])
+
+		if err = parseEnvDuration(env, EnvPrefixUnit+infix+"_RESTART_DELAY", &unit.RestartDelay); err != nil {
+			return
+		}
+		if err = parseEnvDuration(env, EnvPrefixUnit+infix+"_RESTART_MAX_DELAY", &unit.RestartMaxDelay); err != nil {
+			return
+		}
+		if err = parseEnvDuration(env, EnvPrefixUnit+infix+"_RESTART_RESET_AFTER", &unit.RestartResetAfter); err != nil {
+			return
+		}
+
+		if err = parseEnvDuration(env, EnvPrefixUnit+infix+"_START_LIMIT_INTERVAL", &unit.StartLimitInterval); err != nil {
+			return
+		}
+
+		if err = parseEnvInt(env, EnvPrefixUnit+infix+"_START_LIMIT_BURST", &unit.StartLimitBurst); err != nil {
+			return
+		}
+
+		if val := strings.TrimSpace(env[EnvPrefixUnit+infix+"_RESTART_MULTIPLIER"]); val != "" {
+			if unit.RestartMultiplier, err = strconv.ParseFloat(val, 64); err != nil {
+				err = errors.New("invalid $" + EnvPrefixUnit + infix + "_RESTART_MULTIPLIER: " + err.Error())
+				return
+			}
+		}
+	}
+
 	// raw, files
 	if unit.Kind == KindRender {
 		unit.Raw, _ = strconv.ParseBool(env[EnvPrefixUnit+infix+"_RAW"])
@@ -139,9 +232,17 @@ func LoadEnvWithInfix(env map[string]string, infix string) (unit Unit, ok bool,
 		}
 	}
 
+	// rerun on reload
+	if unit.Kind == KindRender || unit.Kind == KindOnce {
+		unit.RerunOnReload, _ = strconv.ParseBool(env[EnvPrefixUnit+infix+"_RERUN_ON_RELOAD"])
+	}
+
 	// critical
 	unit.Critical, _ = strconv.ParseBool(env[EnvPrefixUnit+infix+"_CRITICAL"])
 
+	// essential
+	unit.Essential, _ = strconv.ParseBool(env[EnvPrefixUnit+infix+"_ESSENTIAL"])
+
 	// success codes
 	for _, item := range strings.Split(env[EnvPrefixUnit+infix+"_SUCCESS_CODES"], ",") {
 		item = strings.TrimSpace(item)
@@ -156,11 +257,64 @@ func LoadEnvWithInfix(env map[string]string, infix string) (unit Unit, ok bool,
 	// order
 	unit.Order, _ = strconv.Atoi(env[EnvPrefixUnit+infix+"_ORDER"])
 
+	// after, requires
+	unit.After = splitEnvList(env[EnvPrefixUnit+infix+"_AFTER"])
+	unit.Requires = splitEnvList(env[EnvPrefixUnit+infix+"_REQUIRES"])
+
 	ok = true
 
 	return
 }
 
+// parseEnvDuration parses a duration environment variable if set
+func parseEnvDuration(env map[string]string, key string, out *time.Duration) (err error) {
+	val := strings.TrimSpace(env[key])
+	if val == "" {
+		return
+	}
+	if *out, err = time.ParseDuration(val); err != nil {
+		err = errors.New("invalid $" + key + ": " + err.Error())
+	}
+	return
+}
+
+// parseEnvInt parses an integer environment variable if set
+func parseEnvInt(env map[string]string, key string, out *int) (err error) {
+	val := strings.TrimSpace(env[key])
+	if val == "" {
+		return
+	}
+	if *out, err = strconv.Atoi(val); err != nil {
+		err = errors.New("invalid $" + key + ": " + err.Error())
+	}
+	return
+}
+
+// parseEnvIntPtr is like parseEnvInt, but keeps out nil if not set
+func parseEnvIntPtr(env map[string]string, key string, out **int) (err error) {
+	if strings.TrimSpace(env[key]) == "" {
+		return
+	}
+	var v int
+	if err = parseEnvInt(env, key, &v); err != nil {
+		return
+	}
+	*out = &v
+	return
+}
+
+// splitEnvList splits a comma separated environment variable, empty items are ignored
+func splitEnvList(s string) (items []string) {
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		items = append(items, item)
+	}
+	return
+}
+
 // LoadEnv loads legacy main unit from environment variables
 func LoadEnv(env map[string]string) (unit Unit, ok bool, err error) {
 	cmd := strings.TrimSpace(env["MINIT_MAIN"])
@@ -3,6 +3,7 @@ package munit
 import (
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -24,26 +25,59 @@ func TestDetectEnvInfixes(t *testing.T) {
 
 func TestLoadEnvWithInfix(t *testing.T) {
 	env := map[string]string{
-		"MINIT_UNIT_A1_COMMAND":       "echo 'hello world'",
-		"MINIT_UNIT_A2_COMMAND":       "echo 'hello world'",
-		"MINIT_UNIT_A2_KIND":          "cron",
-		"MINIT_UNIT_A2_CRON":          "* * * * *",
-		"MINIT_UNIT_A2_NAME":          "a2",
-		"MINIT_UNIT_A2_IMMEDIATE":     "true",
-		"MINIT_UNIT_A2_GROUP":         "abc",
-		"MINIT_UNIT_A2_COUNT":         "3",
-		"MINIT_UNIT_A2_DIR":           "/opt",
-		"MINIT_UNIT_A2_SHELL":         "/bin/zsh",
-		"MINIT_UNIT_A2_CHARSET":       "gbk",
-		"MINIT_UNIT_A2_ENV":           "a=b;c=d",
-		"MINIT_UNIT_A2_CRITICAL":      "true",
-		"MINIT_UNIT_A2_SUCCESS_CODES": "114,514",
-		"MINIT_UNIT_A3_COMMAND":       "echo 'hello world'",
-		"MINIT_UNIT_A3_KIND":          "once",
-		"MINIT_UNIT_A3_BLOCKING":      "false",
-		"MINIT_UNIT_A4_KIND":          "render",
-		"MINIT_UNIT_A4_FILES":         "hello.txt;world.txt",
-		"MINIT_UNIT_A4_RAW":           "true",
+		"MINIT_UNIT_A1_COMMAND":              "echo 'hello world'",
+		"MINIT_UNIT_A1_RESTART":              "on-failure",
+		"MINIT_UNIT_A1_PID_FILE":             "/run/a1.pid",
+		"MINIT_UNIT_A1_RESTART_DELAY":        "1s",
+		"MINIT_UNIT_A1_RESTART_MULTIPLIER":   "1.5",
+		"MINIT_UNIT_A1_START_LIMIT_BURST":    "5",
+		"MINIT_UNIT_A1_START_LIMIT_INTERVAL": "2m",
+		"MINIT_UNIT_A2_COMMAND":              "echo 'hello world'",
+		"MINIT_UNIT_A2_KIND":                 "cron",
+		"MINIT_UNIT_A2_CRON":                 "* * * * *",
+		"MINIT_UNIT_A2_NAME":                 "a2",
+		"MINIT_UNIT_A2_IMMEDIATE":            "true",
+		"MINIT_UNIT_A2_TIMEOUT":              "5m",
+		"MINIT_UNIT_A2_GROUP":                "abc",
+		"MINIT_UNIT_A2_COUNT":                "3",
+		"MINIT_UNIT_A2_DIR":                  "/opt",
+		"MINIT_UNIT_A2_CREATE_DIR_MODE":      "0750",
+		"MINIT_UNIT_A2_CHROOT":               "/srv/root",
+		"MINIT_UNIT_A2_UMASK":                "027",
+		"MINIT_UNIT_A2_SHELL":                "/bin/zsh",
+		"MINIT_UNIT_A2_CHARSET":              "gbk",
+		"MINIT_UNIT_A2_ENV":                  "a=b;c=d",
+		"MINIT_UNIT_A2_CRITICAL":             "true",
+		"MINIT_UNIT_A2_SUCCESS_CODES":        "114,514",
+		"MINIT_UNIT_A2_STOP_SIGNAL":          "SIGQUIT",
+		"MINIT_UNIT_A2_KILL_MODE":            "group",
+		"MINIT_UNIT_A2_FORWARD_SIGNALS":      "HUP,SIGUSR1",
+		"MINIT_UNIT_A2_USER":                 "www-data:1000",
+		"MINIT_UNIT_A2_SUPPLEMENTARY_GROUPS": "audio, video",
+		"MINIT_UNIT_A2_CAPABILITIES":         "NET_BIND_SERVICE",
+		"MINIT_UNIT_A2_DROP_CAPABILITIES":    "CAP_SYS_ADMIN, CAP_NET_RAW",
+		"MINIT_UNIT_A2_NO_NEW_PRIVILEGES":    "true",
+		"MINIT_UNIT_A2_RLIMITS":              "nofile=65536:65536; core=unlimited",
+		"MINIT_UNIT_A2_NICE":                 "-5",
+		"MINIT_UNIT_A2_IONICE_CLASS":         "best-effort",
+		"MINIT_UNIT_A2_IONICE_LEVEL":         "0",
+		"MINIT_UNIT_A2_CPU_AFFINITY":         "0-3",
+		"MINIT_UNIT_A2_OOM_SCORE_ADJ":        "500",
+		"MINIT_UNIT_A2_MEMORY_MAX":           "512M",
+		"MINIT_UNIT_A2_CPU_MAX":              "50000 100000",
+		"MINIT_UNIT_A2_PIDS_MAX":             "100",
+		"MINIT_UNIT_A2_IO_WEIGHT":            "50",
+		"MINIT_UNIT_A2_STOP_TIMEOUT":         "30s",
+		"MINIT_UNIT_A3_COMMAND":              "echo 'hello world'",
+		"MINIT_UNIT_A3_KIND":                 "once",
+		"MINIT_UNIT_A3_BLOCKING":             "false",
+		"MINIT_UNIT_A3_ESSENTIAL":            "true",
+		"MINIT_UNIT_A3_AFTER":                "a1, a2",
+		"MINIT_UNIT_A3_REQUIRES":             "a4,",
+		"MINIT_UNIT_A4_KIND":                 "render",
+		"MINIT_UNIT_A4_FILES":                "hello.txt;world.txt",
+		"MINIT_UNIT_A4_RAW":                  "true",
+		"MINIT_UNIT_A4_RERUN_ON_RELOAD":      "true",
 	}
 
 	unit, ok, err := LoadEnvWithInfix(env, "A1")
@@ -56,8 +90,17 @@ func TestLoadEnvWithInfix(t *testing.T) {
 			"echo",
 			"hello world",
 		},
+		PIDFile:           "/run/a1.pid",
+		Restart:           RestartOnFailure,
+		RestartDelay:      time.Second,
+		RestartMultiplier: 1.5,
+
+		StartLimitBurst:    5,
+		StartLimitInterval: time.Minute * 2,
 	}, unit)
 
+	ioniceLevel, oomScoreAdj := 0, 500
+
 	unit, ok, err = LoadEnvWithInfix(env, "A2")
 	require.NoError(t, err)
 	require.True(t, ok)
@@ -69,18 +112,49 @@ func TestLoadEnvWithInfix(t *testing.T) {
 			"hello world",
 		},
 		Immediate: true,
+		Timeout:   time.Minute * 5,
 		Cron:      "* * * * *",
 		Group:     "abc",
 		Count:     3,
 		Dir:       "/opt",
+		CreateDir: &CreateDir{Mode: "0750"},
+		Chroot:    "/srv/root",
+		Umask:     "027",
 		Shell:     "/bin/zsh",
 		Charset:   "gbk",
 		Env: map[string]string{
 			"a": "b",
 			"c": "d",
 		},
-		Critical:     true,
-		SuccessCodes: []int{114, 514},
+		Critical:       true,
+		SuccessCodes:   []int{114, 514},
+		StopSignal:     "SIGQUIT",
+		StopTimeout:    time.Second * 30,
+		KillMode:       "group",
+		ForwardSignals: []string{"HUP", "SIGUSR1"},
+
+		User:                "www-data:1000",
+		SupplementaryGroups: []string{"audio", "video"},
+
+		Capabilities:     []string{"NET_BIND_SERVICE"},
+		DropCapabilities: []string{"CAP_SYS_ADMIN", "CAP_NET_RAW"},
+		NoNewPrivileges:  true,
+
+		RLimits: map[string]string{
+			"nofile": "65536:65536",
+			"core":   "unlimited",
+		},
+
+		Nice:        -5,
+		IONiceClass: "best-effort",
+		IONiceLevel: &ioniceLevel,
+		CPUAffinity: "0-3",
+		OOMScoreAdj: &oomScoreAdj,
+
+		MemoryMax: "512M",
+		CPUMax:    "50000 100000",
+		PIDsMax:   "100",
+		IOWeight:  50,
 	}, unit)
 
 	blockingTrue := false
@@ -89,9 +163,12 @@ func TestLoadEnvWithInfix(t *testing.T) {
 	require.NoError(t, err)
 	require.True(t, ok)
 	require.Equal(t, Unit{
-		Kind:     KindOnce,
-		Name:     "env-a3",
-		Blocking: &blockingTrue,
+		Kind:      KindOnce,
+		Name:      "env-a3",
+		Essential: true,
+		Blocking:  &blockingTrue,
+		After:     []string{"a1", "a2"},
+		Requires:  []string{"a4"},
 		Command: []string{
 			"echo",
 			"hello world",
@@ -109,9 +186,20 @@ func TestLoadEnvWithInfix(t *testing.T) {
 			"hello.txt",
 			"world.txt",
 		},
+		RerunOnReload: true,
 	}, unit)
 }
 
+func TestLoadEnvWithInfixInvalidNumber(t *testing.T) {
+	for _, key := range []string{"START_LIMIT_BURST", "NICE", "START_LIMIT_INTERVAL", "RESTART_MULTIPLIER"} {
+		_, _, err := LoadEnvWithInfix(map[string]string{
+			"MINIT_UNIT_A1_COMMAND": "sleep 100",
+			"MINIT_UNIT_A1_" + key:  "5x",
+		}, "A1")
+		require.ErrorContains(t, err, "invalid $MINIT_UNIT_A1_"+key, key)
+	}
+}
+
 func TestLoadEnv(t *testing.T) {
 	env := map[string]string{
 		"MINIT_MAIN":         "hello 'world destroyer'",
@@ -0,0 +1,60 @@
+package munit
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	DefaultProbeInterval         = time.Second * 10
+	DefaultProbeTimeout          = time.Second
+	DefaultProbeFailureThreshold = 3
+)
+
+// Probe is a periodical health check for 'daemon' units, exactly one of 'exec', 'tcp' and 'http' should be set
+type Probe struct {
+	Exec       []string `yaml:"exec"`        // command to execute, succeeded if exited with 0
+	TCP        string   `yaml:"tcp"`         // address to connect, e.g. 127.0.0.1:6379
+	HTTP       string   `yaml:"http"`        // url to GET, e.g. http://127.0.0.1:8080/healthz
+	HTTPStatus []int    `yaml:"http_status"` // expected http status codes, default is 200-399
+
+	InitialDelay     time.Duration `yaml:"initial_delay"`     // delay before the first check
+	Interval         time.Duration `yaml:"interval"`          // interval between checks, default is 10s
+	Timeout          time.Duration `yaml:"timeout"`           // timeout of a single check, default is 1s
+	FailureThreshold int           `yaml:"failure_threshold"` // consecutive failures to be considered failed, default is 3
+}
+
+// Validate checks the probe has exactly one check method
+func (p Probe) Validate() error {
+	var count int
+	if len(p.Exec) > 0 {
+		count++
+	}
+	if p.TCP != "" {
+		count++
+	}
+	if p.HTTP != "" {
+		count++
+	}
+	if count != 1 {
+		return errors.New("probe must specify exactly one of 'exec', 'tcp' or 'http'")
+	}
+	if p.InitialDelay < 0 || p.Interval < 0 || p.Timeout < 0 || p.FailureThreshold < 0 {
+		return errors.New("probe durations and thresholds must not be negative")
+	}
+	return nil
+}
+
+// WithDefaults returns a copy of probe with default values filled
+func (p Probe) WithDefaults() Probe {
+	if p.Interval == 0 {
+		p.Interval = DefaultProbeInterval
+	}
+	if p.Timeout == 0 {
+		p.Timeout = DefaultProbeTimeout
+	}
+	if p.FailureThreshold == 0 {
+		p.FailureThreshold = DefaultProbeFailureThreshold
+	}
+	return p
+}
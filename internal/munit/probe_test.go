@@ -0,0 +1,49 @@
+package munit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestProbeValidate(t *testing.T) {
+	require.Error(t, Probe{}.Validate())
+	require.Error(t, Probe{TCP: "127.0.0.1:80", HTTP: "http://127.0.0.1"}.Validate())
+	require.Error(t, Probe{TCP: "127.0.0.1:80", Interval: -time.Second}.Validate())
+	require.NoError(t, Probe{Exec: []string{"true"}}.Validate())
+
+	require.NoError(t, Unit{}.RequireValidProbes())
+	require.ErrorContains(t, Unit{Liveness: &Probe{}}.RequireValidProbes(), "liveness")
+}
+
+func TestProbeDecode(t *testing.T) {
+	var unit Unit
+	require.NoError(t, yaml.Unmarshal([]byte(`
+kind: daemon
+name: web
+readiness:
+  http: http://127.0.0.1:8080/healthz
+  http_status: [200, 204]
+  initial_delay: 5s
+  interval: 2s
+liveness:
+  tcp: 127.0.0.1:8080
+  failure_threshold: 5
+`), &unit))
+
+	require.Equal(t, &Probe{
+		HTTP:         "http://127.0.0.1:8080/healthz",
+		HTTPStatus:   []int{200, 204},
+		InitialDelay: time.Second * 5,
+		Interval:     time.Second * 2,
+	}, unit.Readiness)
+
+	require.Equal(t, Probe{
+		TCP:              "127.0.0.1:8080",
+		Interval:         DefaultProbeInterval,
+		Timeout:          DefaultProbeTimeout,
+		FailureThreshold: 5,
+	}, unit.Liveness.WithDefaults())
+}
@@ -2,6 +2,12 @@ package munit
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/yankeguo/minit/internal/mexec"
 	"github.com/yankeguo/minit/internal/mlog"
@@ -18,6 +24,19 @@ const (
 	KindRender = "render"
 )
 
+const (
+	RestartAlways    = "always"
+	RestartOnFailure = "on-failure"
+	RestartNever     = "never"
+
+	DefaultRestartDelay      = time.Second * 5
+	DefaultRestartMaxDelay   = time.Minute
+	DefaultRestartMultiplier = 2.0
+	DefaultRestartResetAfter = time.Minute
+
+	DefaultStartLimitInterval = time.Minute
+)
+
 var (
 	knownUnitKind = map[string]struct{}{
 		KindDaemon: {},
@@ -27,26 +46,87 @@ var (
 	}
 )
 
+// CreateDir are options of creating the working directory of unit
+type CreateDir struct {
+	Mode  string `yaml:"mode"`  // permission in octal, e.g. '0750', default is '0755' if created
+	Owner string `yaml:"owner"` // 'user[:group]', default is unit field 'user' if created
+}
+
 type Unit struct {
-	Kind     string `yaml:"kind"`     // kind of unit
-	Name     string `yaml:"name"`     // name of unit
-	Group    string `yaml:"group"`    // group of unit
-	Count    int    `yaml:"count"`    // replicas of unit
-	Critical bool   `yaml:"critical"` // if true, will halt the minit if unit failed
-	Order    int    `yaml:"order"`    // order of unit
+	Kind      string `yaml:"kind"`      // kind of unit
+	Name      string `yaml:"name"`      // name of unit
+	Group     string `yaml:"group"`     // group of unit
+	Count     int    `yaml:"count"`     // replicas of unit
+	Critical  bool   `yaml:"critical"`  // if true, will halt the minit if unit failed
+	Essential bool   `yaml:"essential"` // if true, minit exits with exit code of the unit once its process exited, 'once' and 'daemon' only
+	Order     int    `yaml:"order"`     // order of unit
+
+	// dependencies, names of units or replicated units
+	After    []string `yaml:"after"`    // wait for these units to be started (daemon, cron) or finished (render, once)
+	Requires []string `yaml:"requires"` // like 'after', but these units must succeed (once, render) or be ready (daemon)
 
 	// execution options, for 'once', 'daemon' and 'cron'
-	Dir          string            `yaml:"dir"`
+	Dir          string            `yaml:"dir"`        // working directory, inside 'chroot' if set
+	CreateDir    *CreateDir        `yaml:"create_dir"` // create 'dir' if set
+	Chroot       string            `yaml:"chroot"`     // root directory of the process
+	Umask        string            `yaml:"umask"`      // umask of the process in octal, e.g. '0027'
 	Shell        string            `yaml:"shell"`
 	Env          map[string]string `yaml:"env"`
 	Command      []string          `yaml:"command"`
 	Charset      string            `yaml:"charset"`
 	SuccessCodes []int             `yaml:"success_codes"` // exit codes that should be treated as success, default is [0]
+	StopSignal   string            `yaml:"stop_signal"`   // signal to stop the process, e.g. SIGQUIT, default is the signal minit received
+	StopTimeout  time.Duration     `yaml:"stop_timeout"`  // time to wait before killing the process with SIGKILL, default is 10s
+
+	KillMode       string   `yaml:"kill_mode"`       // one of 'process' (default), 'group' and 'mixed'
+	ForwardSignals []string `yaml:"forward_signals"` // signals received by minit to forward to the process, e.g. SIGHUP, SIGUSR1
+
+	User                string   `yaml:"user"`                 // 'user[:group]' to run the process as, names or numeric IDs, default is the user of minit
+	SupplementaryGroups []string `yaml:"supplementary_groups"` // supplementary groups of the process, default to groups of the user, or only the group if given in 'user' or the user is unknown
+
+	Capabilities     []string `yaml:"capabilities"`      // only these capabilities are kept, also granted to non-root 'user', e.g. CAP_NET_BIND_SERVICE
+	DropCapabilities []string `yaml:"drop_capabilities"` // capabilities removed from the process
+	NoNewPrivileges  bool     `yaml:"no_new_privileges"` // setuid binaries and file capabilities no longer grant privileges
+
+	RLimits map[string]string `yaml:"rlimits"` // rlimits of the process, e.g. 'nofile: 65536:65536', 'core: unlimited'
+
+	// scheduling options, applied right after the process started
+	Nice        int    `yaml:"nice"`          // -20 (highest priority) to 19 (lowest priority)
+	IONiceClass string `yaml:"ionice_class"`  // one of 'realtime', 'best-effort' and 'idle'
+	IONiceLevel *int   `yaml:"ionice_level"`  // 0 (highest priority) to 7 (lowest priority), default is 4
+	CPUAffinity string `yaml:"cpu_affinity"`  // cpu list, e.g. '0-3,6'
+	OOMScoreAdj *int   `yaml:"oom_score_adj"` // -1000 to 1000, default is the value before 'MINIT_OOM_SCORE_ADJ' applied
+
+	// cgroup v2 resource controls, the process runs in its own cgroup if any is set
+	MemoryMax string `yaml:"memory_max"` // memory.max, e.g. '512M', 'max'
+	CPUMax    string `yaml:"cpu_max"`    // cpu.max, e.g. '50000 100000' for half a cpu, 'max'
+	PIDsMax   string `yaml:"pids_max"`   // pids.max, e.g. '100', 'max'
+	IOWeight  int    `yaml:"io_weight"`  // io.weight, 1 to 10000
+
+	// for 'once' and 'cron'
+	Timeout time.Duration `yaml:"timeout"` // process is stopped with 'stop_signal' if still running after this long, no timeout by default
+
+	// for 'render' and 'once'
+	RerunOnReload bool `yaml:"rerun_on_reload"` // execute again when units are reloaded, even if the unit is not changed
 
 	// for 'render' only
 	Raw   bool     `yaml:"raw"`   // don't trim white spaces for 'render'
 	Files []string `yaml:"files"` // files to process
 
+	// for 'daemon' only
+	PIDFile   string `yaml:"pid_file"`  // absolute path inside 'chroot' of PID file written by a forking daemon, the process in it is supervised once the command exited
+	Readiness *Probe `yaml:"readiness"` // unit is ready only after readiness probe succeeded
+	Liveness  *Probe `yaml:"liveness"`  // process will be restarted if liveness probe failed
+
+	Restart           string        `yaml:"restart"`             // restart policy, one of 'always' (default), 'on-failure' and 'never'
+	RestartDelay      time.Duration `yaml:"restart_delay"`       // delay before the first restart, default is 5s
+	RestartMaxDelay   time.Duration `yaml:"restart_max_delay"`   // maximum delay of exponential backoff, default is 1m
+	RestartMultiplier float64       `yaml:"restart_multiplier"`  // multiplier of exponential backoff, default is 2
+	RestartResetAfter time.Duration `yaml:"restart_reset_after"` // reset the delay if process has been running for this long, default is 1m
+
+	StartLimitBurst    int           `yaml:"start_limit_burst"`    // unit fails if started more than this many times within 'start_limit_interval', 0 means no limit
+	StartLimitInterval time.Duration `yaml:"start_limit_interval"` // window of 'start_limit_burst', default is 1m
+
 	// for 'cron' only
 	Cron      string `yaml:"cron"` // cron syntax
 	Immediate bool   `yaml:"immediate"`
@@ -55,6 +135,37 @@ type Unit struct {
 	Blocking *bool `yaml:"blocking"` // set to false to run once task in background
 }
 
+// Validate checks kind, name and fields shared by all kinds of units, fields of a specific kind are checked when
+// creating runners
+func (u Unit) Validate() error {
+	if _, ok := knownUnitKind[u.Kind]; !ok {
+		return fmt.Errorf("invalid unit kind '%s' for unit '%s': must be one of: render, once, daemon, cron", u.Kind, u.Name)
+	}
+	if !regexpName.MatchString(u.Name) {
+		return fmt.Errorf("invalid unit name '%s': name must start with a letter, contain only alphanumeric characters, hyphens, or underscores, and end with an alphanumeric character", u.Name)
+	}
+
+	for _, fn := range []func() error{
+		u.RequireValidStopSignal,
+		u.RequireValidKillMode,
+		u.RequireValidForwardSignals,
+		u.RequireValidDir,
+		u.RequireValidUser,
+		u.RequireValidCapabilities,
+		u.RequireValidRLimits,
+		u.RequireValidSched,
+		u.RequireValidCgroup,
+		u.RequireValidPIDFile,
+		u.RequireValidTimeout,
+		u.RequireValidEssential,
+	} {
+		if err := fn(); err != nil {
+			return fmt.Errorf("invalid unit '%s': %w", u.Name, err)
+		}
+	}
+	return nil
+}
+
 func (u Unit) RequireCommand() error {
 	if len(u.Command) == 0 {
 		return errors.New("missing unit field 'command': unit must specify at least one command")
@@ -76,17 +187,254 @@ func (u Unit) RequireCron() error {
 	return nil
 }
 
-func (u Unit) ExecuteOptions(logger mlog.ProcLogger) mexec.ExecuteOptions {
-	return mexec.ExecuteOptions{
-		Name: u.Kind + "/" + u.Name,
+func (u Unit) RequireValidProbes() error {
+	if u.Readiness != nil {
+		if err := u.Readiness.Validate(); err != nil {
+			return errors.New("invalid unit field 'readiness': " + err.Error())
+		}
+	}
+	if u.Liveness != nil {
+		if err := u.Liveness.Validate(); err != nil {
+			return errors.New("invalid unit field 'liveness': " + err.Error())
+		}
+	}
+	return nil
+}
+
+func (u Unit) RequireValidRestart() error {
+	switch u.Restart {
+	case "", RestartAlways, RestartOnFailure, RestartNever:
+	default:
+		return errors.New("invalid unit field 'restart': must be one of: always, on-failure, never")
+	}
+	if u.RestartDelay < 0 || u.RestartMaxDelay < 0 || u.RestartResetAfter < 0 {
+		return errors.New("invalid unit fields 'restart_*': durations must not be negative")
+	}
+	if u.StartLimitBurst < 0 || u.StartLimitInterval < 0 {
+		return errors.New("invalid unit fields 'start_limit_*': must not be negative")
+	}
+	if u.RestartMultiplier != 0 && u.RestartMultiplier < 1 {
+		return errors.New("invalid unit field 'restart_multiplier': must not be less than 1")
+	}
+	return nil
+}
+
+func (u Unit) RequireValidEssential() error {
+	if u.Essential && u.Kind != KindOnce && u.Kind != KindDaemon {
+		return errors.New("invalid unit field 'essential': only 'once' and 'daemon' units can be essential")
+	}
+	return nil
+}
+
+func (u Unit) RequireValidPIDFile() error {
+	if u.PIDFile == "" {
+		return nil
+	}
+	if u.Kind != KindDaemon {
+		return errors.New("invalid unit field 'pid_file': only 'daemon' units can have pid file")
+	}
+	if !filepath.IsAbs(u.PIDFile) {
+		return errors.New("invalid unit field 'pid_file': must be an absolute path")
+	}
+	return nil
+}
+
+func (u Unit) RequireValidTimeout() error {
+	if u.Timeout < 0 {
+		return errors.New("invalid unit field 'timeout': must not be negative")
+	}
+	if u.Timeout > 0 && u.Kind != KindOnce && u.Kind != KindCron {
+		return errors.New("invalid unit field 'timeout': only 'once' and 'cron' units can have timeout")
+	}
+	return nil
+}
+
+func (u Unit) RequireValidStopSignal() error {
+	if u.StopSignal == "" {
+		return nil
+	}
+	if _, err := mexec.ParseSignal(u.StopSignal); err != nil {
+		return errors.New("invalid unit field 'stop_signal': " + err.Error())
+	}
+	return nil
+}
+
+func (u Unit) RequireValidKillMode() error {
+	switch u.KillMode {
+	case "", mexec.KillModeProcess, mexec.KillModeGroup, mexec.KillModeMixed:
+		return nil
+	default:
+		return errors.New("invalid unit field 'kill_mode': must be one of: process, group, mixed")
+	}
+}
+
+func (u Unit) RequireValidForwardSignals() error {
+	for _, item := range u.ForwardSignals {
+		sig, err := mexec.ParseSignal(item)
+		if err != nil {
+			return errors.New("invalid unit field 'forward_signals': " + err.Error())
+		}
+		if !slices.Contains(mexec.ForwardableSignals, sig) {
+			return errors.New("invalid unit field 'forward_signals': signal " + item + " can not be forwarded, only SIGHUP, SIGUSR1, SIGUSR2, SIGWINCH and SIGQUIT are supported")
+		}
+	}
+	return nil
+}
+
+func (u Unit) RequireValidDir() error {
+	if u.Umask != "" {
+		if _, err := mexec.ParseFileMode(u.Umask); err != nil {
+			return errors.New("invalid unit field 'umask': " + err.Error())
+		}
+	}
+	if u.Chroot != "" && !filepath.IsAbs(u.Chroot) {
+		return errors.New("invalid unit field 'chroot': must be an absolute path")
+	}
+	if u.CreateDir != nil {
+		if u.Dir == "" {
+			return errors.New("invalid unit field 'create_dir': requires unit field 'dir'")
+		}
+		if u.CreateDir.Mode != "" {
+			if _, err := mexec.ParseFileMode(u.CreateDir.Mode); err != nil {
+				return errors.New("invalid unit field 'create_dir.mode': " + err.Error())
+			}
+		}
+		if name, group, hasGroup := strings.Cut(u.CreateDir.Owner, ":"); hasGroup && (name == "" || group == "") {
+			return errors.New("invalid unit field 'create_dir.owner': must be in format 'user' or 'user:group'")
+		}
+	}
+	return nil
+}
+
+func (u Unit) RequireValidUser() error {
+	if u.User == "" {
+		if len(u.SupplementaryGroups) > 0 {
+			return errors.New("invalid unit field 'supplementary_groups': requires unit field 'user'")
+		}
+		return nil
+	}
+	name, group, hasGroup := strings.Cut(u.User, ":")
+	if name == "" || (hasGroup && group == "") {
+		return errors.New("invalid unit field 'user': must be in format 'user' or 'user:group'")
+	}
+	return nil
+}
+
+func (u Unit) RequireValidCapabilities() error {
+	if err := mexec.ValidateCapabilities(u.Capabilities); err != nil {
+		return errors.New("invalid unit field 'capabilities': " + err.Error())
+	}
+	if err := mexec.ValidateCapabilities(u.DropCapabilities); err != nil {
+		return errors.New("invalid unit field 'drop_capabilities': " + err.Error())
+	}
+	return nil
+}
+
+func (u Unit) RequireValidRLimits() error {
+	if err := mexec.ValidateRLimits(u.RLimits); err != nil {
+		return errors.New("invalid unit field 'rlimits': " + err.Error())
+	}
+	return nil
+}
+
+func (u Unit) RequireValidCgroup() error {
+	if err := u.CgroupOptions().Validate(); err != nil {
+		return errors.New("invalid unit cgroup fields: " + err.Error())
+	}
+	return nil
+}
+
+func (u Unit) RequireValidSched() error {
+	if err := u.SchedOptions().Validate(); err != nil {
+		return errors.New("invalid unit scheduling fields: " + err.Error())
+	}
+	return nil
+}
+
+// SchedOptions returns scheduling options of the unit
+func (u Unit) SchedOptions() mexec.SchedOptions {
+	return mexec.SchedOptions{
+		Nice:        u.Nice,
+		IONiceClass: u.IONiceClass,
+		IONiceLevel: u.IONiceLevel,
+		CPUAffinity: u.CPUAffinity,
+		OOMScoreAdj: u.OOMScoreAdj,
+	}
+}
+
+// CgroupOptions returns cgroup v2 resource controls of the unit
+func (u Unit) CgroupOptions() mexec.CgroupOptions {
+	return mexec.CgroupOptions{
+		MemoryMax: u.MemoryMax,
+		CPUMax:    u.CPUMax,
+		PIDsMax:   u.PIDsMax,
+		IOWeight:  u.IOWeight,
+	}
+}
+
+// ForwardsSignal returns true if the signal is listed in 'forward_signals'
+func (u Unit) ForwardsSignal(sig os.Signal) bool {
+	for _, item := range u.ForwardSignals {
+		if s, err := mexec.ParseSignal(item); err == nil && s == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// ID returns the identity of unit in format 'kind/name', it is also the name of executions of the unit
+func (u Unit) ID() string {
+	return u.Kind + "/" + u.Name
+}
+
+func (u Unit) ExecuteOptions(logger mlog.ProcLogger) (opts mexec.ExecuteOptions) {
+	opts = mexec.ExecuteOptions{
+		Name: u.ID(),
 
 		Dir:          u.Dir,
+		Chroot:       u.Chroot,
 		Shell:        u.Shell,
 		Env:          u.Env,
 		Command:      u.Command,
 		Charset:      u.Charset,
 		SuccessCodes: u.SuccessCodes,
+		StopTimeout:  u.StopTimeout,
+		KillMode:     u.KillMode,
+		PIDFile:      u.PIDFile,
+
+		User:                u.User,
+		SupplementaryGroups: u.SupplementaryGroups,
+
+		Capabilities:     u.Capabilities,
+		DropCapabilities: u.DropCapabilities,
+		NoNewPrivileges:  u.NoNewPrivileges,
+
+		RLimits: u.RLimits,
+		Cgroup:  u.CgroupOptions(),
+		Sched:   u.SchedOptions(),
 
 		Logger: logger,
 	}
+
+	if u.Umask != "" {
+		if mode, err := mexec.ParseFileMode(u.Umask); err == nil {
+			umask := int(mode)
+			opts.Umask = &umask
+		}
+	}
+
+	if u.CreateDir != nil {
+		opts.CreateDir = &mexec.CreateDirOptions{Owner: u.CreateDir.Owner}
+		if u.CreateDir.Mode != "" {
+			opts.CreateDir.Mode, _ = mexec.ParseFileMode(u.CreateDir.Mode)
+		}
+	}
+
+	if u.StopSignal != "" {
+		if sig, err := mexec.ParseSignal(u.StopSignal); err == nil {
+			opts.StopSignal = sig
+		}
+	}
+
+	return opts
 }
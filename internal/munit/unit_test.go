@@ -0,0 +1,162 @@
+package munit
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yankeguo/minit/internal/mexec"
+	"gopkg.in/yaml.v3"
+)
+
+func TestUnitExecuteOptions(t *testing.T) {
+	unit := Unit{
+		Kind:        KindDaemon,
+		Name:        "nginx",
+		Command:     []string{"nginx"},
+		StopSignal:  "QUIT",
+		StopTimeout: time.Second * 30,
+	}
+	require.NoError(t, unit.RequireValidStopSignal())
+
+	opts := unit.ExecuteOptions(nil)
+	require.Equal(t, "daemon/nginx", opts.Name)
+	require.Equal(t, syscall.SIGQUIT, opts.StopSignal)
+	require.Equal(t, time.Second*30, opts.StopTimeout)
+
+	unit.StopSignal = "SIGNOTHING"
+	require.Error(t, unit.RequireValidStopSignal())
+
+	unit.StopSignal = ""
+	require.Nil(t, unit.ExecuteOptions(nil).StopSignal)
+}
+
+func TestUnitValidate(t *testing.T) {
+	require.NoError(t, Unit{Kind: KindDaemon, Name: "web", Restart: RestartAlways}.Validate())
+	require.ErrorContains(t, Unit{Kind: "service", Name: "web"}.Validate(), "invalid unit kind")
+	require.ErrorContains(t, Unit{Kind: KindDaemon, Name: "web-"}.Validate(), "invalid unit name")
+	require.ErrorContains(t, Unit{Kind: KindDaemon, Name: "web", KillMode: "all"}.Validate(), "invalid unit 'web': invalid unit field 'kill_mode'")
+	require.NoError(t, Unit{Kind: KindOnce, Name: "web", Restart: "sometimes"}.Validate())
+}
+
+func TestUnitRequireValidRestart(t *testing.T) {
+	require.NoError(t, Unit{}.RequireValidRestart())
+	require.NoError(t, Unit{Restart: RestartOnFailure, RestartMultiplier: 1.5}.RequireValidRestart())
+	require.Error(t, Unit{Restart: "sometimes"}.RequireValidRestart())
+	require.Error(t, Unit{RestartDelay: -time.Second}.RequireValidRestart())
+	require.Error(t, Unit{RestartMultiplier: 0.5}.RequireValidRestart())
+}
+
+func TestUnitRequireValidPIDFile(t *testing.T) {
+	require.NoError(t, Unit{Kind: KindOnce}.RequireValidPIDFile())
+	require.NoError(t, Unit{Kind: KindDaemon, PIDFile: "/run/httpd.pid"}.RequireValidPIDFile())
+	require.Error(t, Unit{Kind: KindDaemon, PIDFile: "httpd.pid"}.RequireValidPIDFile())
+	require.Error(t, Unit{Kind: KindCron, PIDFile: "/run/httpd.pid"}.RequireValidPIDFile())
+}
+
+func TestUnitRequireValidTimeout(t *testing.T) {
+	require.NoError(t, Unit{Kind: KindDaemon}.RequireValidTimeout())
+	require.NoError(t, Unit{Kind: KindOnce, Timeout: time.Minute}.RequireValidTimeout())
+	require.NoError(t, Unit{Kind: KindCron, Timeout: time.Minute}.RequireValidTimeout())
+	require.Error(t, Unit{Kind: KindDaemon, Timeout: time.Minute}.RequireValidTimeout())
+	require.Error(t, Unit{Kind: KindOnce, Timeout: -time.Minute}.RequireValidTimeout())
+}
+
+func TestUnitRequireValidEssential(t *testing.T) {
+	require.NoError(t, Unit{Kind: KindCron}.RequireValidEssential())
+	require.NoError(t, Unit{Kind: KindOnce, Essential: true}.RequireValidEssential())
+	require.NoError(t, Unit{Kind: KindDaemon, Essential: true}.RequireValidEssential())
+	require.Error(t, Unit{Kind: KindCron, Essential: true}.RequireValidEssential())
+	require.Error(t, Unit{Kind: KindRender, Essential: true}.RequireValidEssential())
+}
+
+func TestUnitForwardSignals(t *testing.T) {
+	unit := Unit{ForwardSignals: []string{"HUP", "SIGUSR2"}}
+	require.NoError(t, unit.RequireValidForwardSignals())
+	require.True(t, unit.ForwardsSignal(syscall.SIGHUP))
+	require.True(t, unit.ForwardsSignal(syscall.SIGUSR2))
+	require.False(t, unit.ForwardsSignal(syscall.SIGUSR1))
+
+	require.Error(t, Unit{ForwardSignals: []string{"SIGTERM"}}.RequireValidForwardSignals())
+	require.Error(t, Unit{ForwardSignals: []string{"SIGNOTHING"}}.RequireValidForwardSignals())
+}
+
+func TestUnitRequireValidDir(t *testing.T) {
+	require.NoError(t, Unit{}.RequireValidDir())
+	require.NoError(t, Unit{Dir: "/run/app", CreateDir: &CreateDir{Mode: "0750", Owner: "app:app"}, Chroot: "/srv", Umask: "0027"}.RequireValidDir())
+	require.Error(t, Unit{Umask: "0999"}.RequireValidDir())
+	require.Error(t, Unit{Chroot: "srv"}.RequireValidDir())
+	require.Error(t, Unit{CreateDir: &CreateDir{}}.RequireValidDir())
+	require.Error(t, Unit{Dir: "/run/app", CreateDir: &CreateDir{Mode: "rwx"}}.RequireValidDir())
+	require.Error(t, Unit{Dir: "/run/app", CreateDir: &CreateDir{Owner: "app:"}}.RequireValidDir())
+
+	opts := Unit{Dir: "/run/app", CreateDir: &CreateDir{Mode: "0750"}, Umask: "027"}.ExecuteOptions(nil)
+	require.Equal(t, &mexec.CreateDirOptions{Mode: 0750}, opts.CreateDir)
+	require.Equal(t, 027, *opts.Umask)
+}
+
+func TestUnitRequireValidUser(t *testing.T) {
+	require.NoError(t, Unit{}.RequireValidUser())
+	require.NoError(t, Unit{User: "www-data"}.RequireValidUser())
+	require.NoError(t, Unit{User: "1000:1000", SupplementaryGroups: []string{"audio"}}.RequireValidUser())
+	require.Error(t, Unit{User: ":1000"}.RequireValidUser())
+	require.Error(t, Unit{User: "www-data:"}.RequireValidUser())
+	require.Error(t, Unit{SupplementaryGroups: []string{"audio"}}.RequireValidUser())
+
+	opts := Unit{User: "www-data", SupplementaryGroups: []string{"audio"}}.ExecuteOptions(nil)
+	require.Equal(t, "www-data", opts.User)
+	require.Equal(t, []string{"audio"}, opts.SupplementaryGroups)
+}
+
+func TestUnitRequireValidCapabilities(t *testing.T) {
+	require.NoError(t, Unit{}.RequireValidCapabilities())
+	require.NoError(t, Unit{Capabilities: []string{"NET_BIND_SERVICE"}, DropCapabilities: []string{"cap_sys_admin"}}.RequireValidCapabilities())
+	require.Error(t, Unit{Capabilities: []string{"NET_BIND"}}.RequireValidCapabilities())
+	require.Error(t, Unit{DropCapabilities: []string{"ALL"}}.RequireValidCapabilities())
+
+	opts := Unit{Capabilities: []string{"NET_BIND_SERVICE"}, NoNewPrivileges: true}.ExecuteOptions(nil)
+	require.Equal(t, []string{"NET_BIND_SERVICE"}, opts.Capabilities)
+	require.True(t, opts.NoNewPrivileges)
+}
+
+func TestUnitRequireValidRLimits(t *testing.T) {
+	require.NoError(t, Unit{}.RequireValidRLimits())
+	require.NoError(t, Unit{RLimits: map[string]string{"nofile": "65536:65536", "core": "unlimited"}}.RequireValidRLimits())
+	require.Error(t, Unit{RLimits: map[string]string{"nofiles": "65536"}}.RequireValidRLimits())
+	require.Error(t, Unit{RLimits: map[string]string{"nofile": "lots"}}.RequireValidRLimits())
+
+	var unit Unit
+	require.NoError(t, yaml.Unmarshal([]byte("rlimits:\n  nofile: 65536\n  nproc: 1024:2048\n"), &unit))
+	require.Equal(t, map[string]string{"nofile": "65536", "nproc": "1024:2048"}, unit.RLimits)
+}
+
+func TestUnitRequireValidSched(t *testing.T) {
+	level, oom := 2, -1001
+
+	require.NoError(t, Unit{}.RequireValidSched())
+	require.NoError(t, Unit{Nice: 10, IONiceClass: "idle", CPUAffinity: "0-3,6"}.RequireValidSched())
+	require.Error(t, Unit{IONiceLevel: &level}.RequireValidSched())
+	require.Error(t, Unit{OOMScoreAdj: &oom}.RequireValidSched())
+
+	require.Equal(t, mexec.SchedOptions{Nice: 10, IONiceClass: "realtime", IONiceLevel: &level}, Unit{Nice: 10, IONiceClass: "realtime", IONiceLevel: &level}.ExecuteOptions(nil).Sched)
+}
+
+func TestUnitRequireValidCgroup(t *testing.T) {
+	require.NoError(t, Unit{}.RequireValidCgroup())
+	require.NoError(t, Unit{MemoryMax: "512M", CPUMax: "50000 100000", PIDsMax: "max", IOWeight: 100}.RequireValidCgroup())
+	require.Error(t, Unit{MemoryMax: "512MB"}.RequireValidCgroup())
+	require.Error(t, Unit{CPUMax: "0.5"}.RequireValidCgroup())
+	require.Error(t, Unit{PIDsMax: "-1"}.RequireValidCgroup())
+	require.Error(t, Unit{IOWeight: 10001}.RequireValidCgroup())
+
+	require.Equal(t, mexec.CgroupOptions{MemoryMax: "1G"}, Unit{MemoryMax: "1G"}.ExecuteOptions(nil).Cgroup)
+}
+
+func TestUnitRequireValidKillMode(t *testing.T) {
+	require.NoError(t, Unit{}.RequireValidKillMode())
+	require.NoError(t, Unit{KillMode: "mixed"}.RequireValidKillMode())
+	require.Error(t, Unit{KillMode: "all"}.RequireValidKillMode())
+
+	require.Equal(t, "group", Unit{KillMode: "group"}.ExecuteOptions(nil).KillMode)
+}
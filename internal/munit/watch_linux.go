@@ -0,0 +1,73 @@
+//go:build linux
+
+package munit
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	watchMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_CLOSE_WRITE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ATTRIB
+)
+
+// WatchDirs watches unit directories with inotify, fn is called once changes settled for the debounce duration.
+// Atomic '..data' symlink swaps of Kubernetes ConfigMap are renames in the watched directory, so they are covered.
+func WatchDirs(ctx context.Context, dirs []string, debounce time.Duration, fn func()) (err error) {
+	var fd int
+	if fd, err = unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK); err != nil {
+		return
+	}
+
+	// non-blocking fd is registered to runtime poller, Close() unblocks pending Read()
+	f := os.NewFile(uintptr(fd), "inotify")
+
+	for _, dir := range dirs {
+		if _, err = unix.InotifyAddWatch(fd, dir, watchMask); err != nil {
+			_ = f.Close()
+			return
+		}
+	}
+
+	chEvent := make(chan struct{}, 1)
+
+	go func() {
+		<-ctx.Done()
+		_ = f.Close()
+	}()
+
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			if _, err := f.Read(buf); err != nil {
+				return
+			}
+			select {
+			case chEvent <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	go func() {
+		timer := time.NewTimer(debounce)
+		timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-chEvent:
+				timer.Reset(debounce)
+			case <-timer.C:
+				fn()
+			}
+		}
+	}()
+
+	return
+}
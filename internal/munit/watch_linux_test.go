@@ -0,0 +1,56 @@
+//go:build linux
+
+package munit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchDirs(t *testing.T) {
+	dir := t.TempDir()
+
+	// layout of a mounted Kubernetes ConfigMap
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "..v1"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "..v1", "a.yml"), []byte("kind: once"), 0644))
+	require.NoError(t, os.Symlink("..v1", filepath.Join(dir, "..data")))
+	require.NoError(t, os.Symlink(filepath.Join("..data", "a.yml"), filepath.Join(dir, "a.yml")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var count atomic.Int32
+
+	require.NoError(t, WatchDirs(ctx, []string{dir}, time.Millisecond*200, func() {
+		count.Add(1)
+	}))
+
+	// atomic swap, multiple events within debounce duration trigger only once
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "..v2"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "..v2", "a.yml"), []byte("kind: daemon"), 0644))
+	require.NoError(t, os.Symlink("..v2", filepath.Join(dir, "..data_tmp")))
+	require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "..v1")))
+
+	time.Sleep(time.Millisecond * 500)
+	require.Equal(t, int32(1), count.Load())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.yml"), []byte("kind: once"), 0644))
+
+	time.Sleep(time.Millisecond * 500)
+	require.Equal(t, int32(2), count.Load())
+
+	cancel()
+	time.Sleep(time.Millisecond * 100)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "c.yml"), []byte("kind: once"), 0644))
+
+	time.Sleep(time.Millisecond * 500)
+	require.Equal(t, int32(2), count.Load())
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package munit
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WatchDirs is only supported on linux
+func WatchDirs(ctx context.Context, dirs []string, debounce time.Duration, fn func()) (err error) {
+	return errors.New("watching unit directories is not supported on this platform")
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
@@ -9,13 +10,14 @@ import (
 	"os/signal"
 	"strconv"
 	"strings"
-	"sync"
 	"syscall"
 	"time"
 
+	"github.com/yankeguo/minit/internal/mctl"
 	"github.com/yankeguo/minit/internal/menv"
 	"github.com/yankeguo/minit/internal/mexec"
 	"github.com/yankeguo/minit/internal/mlog"
+	"github.com/yankeguo/minit/internal/mmetrics"
 	"github.com/yankeguo/minit/internal/mrunners"
 	"github.com/yankeguo/minit/internal/msetups"
 	"github.com/yankeguo/minit/internal/munit"
@@ -26,14 +28,32 @@ var (
 	AppVersion = "unknown"
 )
 
+const (
+	reloadWatchDebounce = time.Second * 2
+)
+
 func exit(err *error) {
 	if *err == nil {
 		return
 	}
+	// essential unit exited, exit with its exit code
+	var ee *mrunners.ExitError
+	if errors.As(*err, &ee) {
+		os.Exit(ee.Code)
+	}
 	_, _ = fmt.Fprintf(os.Stderr, "%s: exited with error: %s\n", "minit", (*err).Error())
 	os.Exit(1)
 }
 
+func isForwardable(sig os.Signal) bool {
+	for _, fsig := range mexec.ForwardableSignals {
+		if sig == fsig {
+			return true
+		}
+	}
+	return false
+}
+
 func envStr(key string, out *string) {
 	if val := strings.TrimSpace(os.Getenv(key)); val != "" {
 		*out = val
@@ -46,6 +66,16 @@ func envBool(key string, out *bool) {
 	}
 }
 
+func envDuration(key string, out *time.Duration) {
+	if val := strings.TrimSpace(os.Getenv(key)); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			*out = d
+		} else if secs, err := strconv.Atoi(val); err == nil {
+			*out = time.Duration(secs) * time.Second
+		}
+	}
+}
+
 func main() {
 	var err error
 	defer exit(&err)
@@ -56,8 +86,49 @@ func main() {
 		optUnitDir   = "/etc/minit.d"
 		optLogDir    = ""
 		optQuickExit bool
+
+		optShutdownDelay   time.Duration
+		optShutdownTimeout = time.Second * 30
+
+		optControlSocket = mctl.DefaultSocket
+
+		optReloadWatch bool
+
+		optMetricsPort = ""
+
+		optCgroupRoot = mexec.DefaultCgroupRoot
 	)
 
+	// exec helper mode, apply privileges and execute the command of a unit
+	if mexec.IsHelper(os.Args) {
+		err = mexec.RunHelper(os.Args[2:])
+		return
+	}
+
+	// su-exec mode, switch user and execute the command in place, e.g. minit linked as su-exec
+	if mexec.IsSuExec(os.Args) {
+		err = mexec.RunSuExec(os.Args[1:])
+		return
+	}
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "exec":
+			err = mexec.RunExec(os.Args[2:])
+			return
+		case mexec.SuExecName:
+			err = mexec.RunSuExec(os.Args[2:])
+			return
+		}
+	}
+
+	envStr("MINIT_CONTROL_SOCKET", &optControlSocket)
+
+	// client mode, talk to the running minit
+	if len(os.Args) > 1 && os.Args[1] == "ctl" {
+		err = mctl.NewClient(optControlSocket).Run(os.Args[2:], os.Stdout)
+		return
+	}
+
 	// pprof debugging server (non-critical)
 	if envStr("MINIT_PPROF_PORT", &optPprofPort); optPprofPort != "" {
 		go func() {
@@ -71,65 +142,134 @@ func main() {
 	envStr("MINIT_UNIT_DIR", &optUnitDir)
 	envStr("MINIT_LOG_DIR", &optLogDir)
 	envBool("MINIT_QUICK_EXIT", &optQuickExit)
+	envDuration("MINIT_SHUTDOWN_DELAY", &optShutdownDelay)
+	envDuration("MINIT_SHUTDOWN_TIMEOUT", &optShutdownTimeout)
+	envBool("MINIT_RELOAD_WATCH", &optReloadWatch)
+	envStr("MINIT_CGROUP_ROOT", &optCgroupRoot)
 
 	log := rg.Must(mlog.CreateSimpleLogger(optLogDir, "minit", "minit: "))
 
-	exem := mexec.NewManager()
+	exem := mexec.NewManager(mexec.ManagerOptions{
+		CgroupRoot: optCgroupRoot,
+	})
 
 	log.Print("starting (" + AppVersion + ")")
 
 	// run through setups
 	rg.Must0(msetups.Setup(log))
 
-	// load units
-	units, skips := rg.Must2(
-		munit.Load(
-			munit.LoadOptions{
-				Args: os.Args[1:],
-				Env:  menv.Environ(),
-				Dirs: munit.ParseUnitDirPattern(optUnitDir),
-			},
-		),
-	)
-
-	for _, skip := range skips {
-		log.Print("unit skipped: " + skip.Name)
-	}
+	unitDirs := munit.ParseUnitDirPattern(optUnitDir)
 
-	// load runners
 	var (
-		runnersS []mrunners.Runner
-		runnersL []mrunners.Runner
+		sup      = mrunners.NewSupervisor(exem, log)
+		registry = mrunners.NewRegistry()
+		loggers  = map[string]mlog.ProcLogger{}
 	)
 
-	{
-		var runners []mrunners.Runner
+	// loadRunners loads units and converts them to runners, loggers are reused across reloads
+	loadRunners := func() (runners []mrunners.Runner, err error) {
+		defer rg.Guard(&err)
+
+		units, skips := rg.Must2(
+			munit.Load(
+				munit.LoadOptions{
+					Args: os.Args[1:],
+					Env:  menv.Environ(),
+					Dirs: unitDirs,
+				},
+			),
+		)
+
+		for _, skip := range skips {
+			log.Print("unit skipped: " + skip.Name)
+		}
 
-		// convert units to runners
 		for _, unit := range units {
+			logger, ok := loggers[unit.Name]
+			if !ok {
+				logger = rg.Must(mlog.CreateSimpleLogger(optLogDir, unit.Name, ""))
+				loggers[unit.Name] = logger
+			}
+
 			runners = append(
 				runners,
 				rg.Must(mrunners.Create(mrunners.RunnerOptions{
-					Unit:   unit,
-					Exec:   exem,
-					Logger: rg.Must(mlog.CreateSimpleLogger(optLogDir, unit.Name, "")),
+					Unit:     unit,
+					Exec:     exem,
+					Logger:   logger,
+					Registry: registry,
+
+					ReportError: sup.ReportError,
 				})),
 			)
 		}
+		return
+	}
 
-		// split short runners and long runners
-		for _, runner := range runners {
-			if runner.Long {
-				runnersL = append(runnersL, runner)
-			} else {
-				runnersS = append(runnersS, runner)
+	// load runners
+	var (
+		runnersS []mrunners.Runner
+		runnersL []mrunners.Runner
+	)
+
+	// split short runners and long runners
+	for _, runner := range rg.Must(loadRunners()) {
+		if runner.Long {
+			runnersL = append(runnersL, runner)
+		} else {
+			runnersS = append(runnersS, runner)
+		}
+	}
+
+	// control socket (non-critical)
+	if optControlSocket != mctl.SocketDisabled {
+		ctl := mctl.NewServer(mctl.ServerOptions{
+			Socket:     optControlSocket,
+			Supervisor: sup,
+			Logger:     log,
+		})
+		if ctlErr := ctl.Start(); ctlErr != nil {
+			log.Errorf("failed starting control socket %s: %s", optControlSocket, ctlErr.Error())
+		}
+		defer ctl.Close()
+	}
+
+	// metrics server (non-critical)
+	if envStr("MINIT_METRICS_PORT", &optMetricsPort); optMetricsPort != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", mmetrics.Handler(sup))
+
+		go func() {
+			if metricsErr := http.ListenAndServe(":"+optMetricsPort, mux); metricsErr != nil {
+				log.Errorf("metrics server on port %s failed: %s", optMetricsPort, metricsErr.Error())
 			}
+		}()
+	}
+
+	caught := func(err error) {
+		var ee *mrunners.ExitError
+		if errors.As(err, &ee) {
+			log.Print(ee.Error())
+		} else {
+			log.Printf("critical error caught: %s", err.Error())
 		}
 	}
 
+	// stop units in order, exit as soon as every process exited, or kill all after shutdown timeout
+	shutdown := func(ctx context.Context, sig os.Signal) {
+		ctx, cancel := context.WithTimeout(ctx, optShutdownTimeout)
+		defer cancel()
+
+		sup.Shutdown(ctx, sig)
+
+		log.Print("all units stopped")
+	}
+
 	// execute short runners
 	for _, runner := range runnersS {
-		if err = runner.Action.Do(context.Background()); err != nil {
+		if err = sup.Run(runner); err != nil {
+			caught(err)
+			shutdown(context.Background(), syscall.SIGTERM)
 			return
 		}
 	}
@@ -141,54 +281,138 @@ func main() {
 	}
 
 	// run long runners
-	ctx, cancel := context.WithCancel(context.Background())
-	wg := &sync.WaitGroup{}
-	chErr := make(chan error, 1)
-
 	for _, runner := range runnersL {
-		wg.Add(1)
-		go func(runner mrunners.Runner) {
-			if err := runner.Action.Do(ctx); err != nil {
-				select {
-				case chErr <- err:
-				default:
-				}
+		sup.Start(runner)
+	}
+
+	// reload units on SIGHUP, or on changes of unit directories
+	chReload := make(chan struct{}, 1)
+
+	if optReloadWatch {
+		if watchErr := munit.WatchDirs(context.Background(), unitDirs, reloadWatchDebounce, func() {
+			select {
+			case chReload <- struct{}{}:
+			default:
 			}
-			wg.Done()
-		}(runner)
+		}); watchErr != nil {
+			log.Errorf("failed watching unit directories: %s", watchErr.Error())
+		}
+	}
+
+	// reload runs off the signal loop, so that shutdown signals are still handled while short runners of the reload
+	// are running, they are cancelled once shutting down
+	ctxReload, cancelReload := context.WithCancel(context.Background())
+
+	var (
+		chReloadErr   = make(chan error, 1)
+		reloading     bool
+		reloadPending bool
+	)
+
+	reload := func() {
+		if reloading {
+			reloadPending = true
+			return
+		}
+		reloading = true
+
+		log.Print("reloading units")
+
+		go func() {
+			runners, loadErr := loadRunners()
+			if loadErr != nil {
+				log.Errorf("failed reloading units, keep running: %s", loadErr.Error())
+				chReloadErr <- nil
+				return
+			}
+
+			chReloadErr <- sup.Reload(ctxReload, runners)
+		}()
 	}
 
 	// wait for signals
 	chSig := make(chan os.Signal, 1)
 	signal.Notify(chSig, syscall.SIGINT, syscall.SIGTERM)
+	for _, fsig := range mexec.ForwardableSignals {
+		signal.Notify(chSig, fsig)
+	}
 
 	var sig os.Signal
 
-	select {
-	case sig = <-chSig:
-		log.Printf("signal caught: %s", sig.String())
-	case err = <-chErr:
-		log.Printf("critical error caught: %s", err.Error())
-	}
+waitLoop:
+	for {
+		select {
+		case sig = <-chSig:
+			log.Printf("signal caught: %s", sig.String())
 
-	if sig == nil {
-		sig = syscall.SIGTERM
+			if !isForwardable(sig) {
+				break waitLoop
+			}
+
+			sup.ForwardSignal(sig)
+
+			if sig == syscall.SIGHUP {
+				reload()
+			}
+		case <-chReload:
+			reload()
+		case err = <-chReloadErr:
+			reloading = false
+
+			if err != nil {
+				caught(err)
+				sig = nil
+				break waitLoop
+			}
+
+			// changes arrived while reloading
+			if reloadPending {
+				reloadPending = false
+				reload()
+			}
+		case err = <-sup.Err():
+			caught(err)
+			break waitLoop
+		}
 	}
 
-	// Graceful shutdown sequence:
-	// 1. Cancel context to signal all long runners to stop
-	cancel()
+	// cancel reload in progress
+	cancelReload()
+
+	// a second signal forces an immediate kill
+	ctxForce, cancelForce := context.WithCancel(context.Background())
+	defer cancelForce()
+
+	go func() {
+		for {
+			select {
+			case sigAgain := <-chSig:
+				if isForwardable(sigAgain) {
+					sup.ForwardSignal(sigAgain)
+					continue
+				}
+				log.Printf("signal caught again: %s, killing all processes", sigAgain.String())
+				cancelForce()
+				return
+			case <-ctxForce.Done():
+				return
+			}
+		}
+	}()
 
-	// 2. Wait 3 seconds for graceful shutdown
-	//    This gives daemons and cron jobs time to complete their current operations
-	//    and clean up resources before forceful termination
-	time.Sleep(time.Second * 3)
+	// pre-stop delay, give load balancers time to remove this container from endpoints
+	if sig != nil && optShutdownDelay > 0 {
+		log.Printf("waiting %s before shutdown", optShutdownDelay)
 
-	// 3. Broadcast signal to all managed child processes
-	//    This ensures any remaining processes are notified to terminate
-	exem.Signal(sig)
+		select {
+		case <-time.After(optShutdownDelay):
+		case <-ctxForce.Done():
+		}
+	}
+
+	if sig == nil {
+		sig = syscall.SIGTERM
+	}
 
-	// 4. Wait for all long runner goroutines to complete
-	//    This ensures proper cleanup and prevents resource leaks
-	wg.Wait()
+	shutdown(ctxForce, sig)
 }